@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
 	"github.com/aetherspritee/nikki/src"
 	tea "github.com/charmbracelet/bubbletea"
 	"os"
+	"strings"
 )
 
 // TODOS
@@ -12,7 +15,6 @@ import (
 // TODO: last open restructuring todo
 // TODO: fully support monthly view
 // TODO: build and refactor rules
-// TODO: better data storage solution
 // TODO: add mouse support show info for single day (idek if thats possible)
 
 // ############################################
@@ -20,13 +22,58 @@ import (
 // ############################################
 
 func main() {
+	configFlag := flag.String("config", "", "path to config.toml (default $XDG_CONFIG_HOME/nikki/config.toml)")
+	dataFlag := flag.String("data", "", "path to data.json (default $XDG_DATA_HOME/nikki/data.json)")
+	flag.Parse()
+
+	src.SetPaths(*configFlag, *dataFlag)
+	if err := src.EnsurePaths(); err != nil {
+		fmt.Printf("Couldn't set up nikki's files: %v\n", err)
+		os.Exit(1)
+	}
+	if flag.Arg(0) == "restore" {
+		restore(flag.Arg(1))
+		return
+	}
 	// encodeJson()
 	// decodeJson()
-	src.ReadConfig()
+	cfg := src.ReadConfig()
+	store, err := src.OpenStore(cfg)
+	if err != nil {
+		fmt.Printf("Couldn't open nikki's data: %v\n", err)
+		os.Exit(1)
+	}
+	defer store.Close()
 
-	p := tea.NewProgram(src.InitialModel())
+	if flag.Arg(0) == "archive" {
+		if err := archive(store, flag.Arg(1), flag.Arg(2)); err != nil {
+			fmt.Println(err)
+			store.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "export" {
+		if err := src.Export(store, flag.Arg(1), os.Stdout); err != nil {
+			fmt.Printf("Couldn't export data: %v\n", err)
+			store.Close()
+			os.Exit(1)
+		}
+		return
+	}
+
+	m, err := src.InitialModel(store)
+	if err != nil {
+		fmt.Printf("Couldn't load nikki's data: %v\n", err)
+		store.Close()
+		os.Exit(1)
+	}
+
+	p := tea.NewProgram(m)
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
+		store.Close()
 		os.Exit(1)
 	}
 
@@ -38,3 +85,67 @@ func main() {
 	// if res != 0 {
 	// }
 }
+
+// nikki restore lists the backups, nikki restore <n> rolls back to one of them
+func restore(which string) {
+	if which == "" {
+		backups, err := src.ListBackups()
+		if err != nil {
+			fmt.Printf("Couldn't list backups: %v\n", err)
+			os.Exit(1)
+		}
+		if len(backups) == 0 {
+			fmt.Println("No backups yet.")
+			return
+		}
+		for idx, backup := range backups {
+			fmt.Printf("%3d  %v\n", idx+1, backup)
+		}
+		fmt.Println("\nRun nikki restore <number> to roll back to one of them.")
+		return
+	}
+	if err := src.RestoreBackup(which); err != nil {
+		fmt.Printf("Couldn't restore backup: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Restored backup %v.\n", which)
+}
+
+// nikki archive lists archived metrics,
+// nikki archive restore|purge <name> brings one back or deletes it for good
+func archive(store src.Store, action string, name string) error {
+	switch action {
+	case "":
+		archived, err := src.ArchivedMetrics(store)
+		if err != nil {
+			return err
+		}
+		if len(archived) == 0 {
+			fmt.Println("No archived metrics.")
+			return nil
+		}
+		for _, metric := range archived {
+			fmt.Printf("%v (%d days)\n", metric.Name, metric.Days)
+		}
+		fmt.Println("\nRun nikki archive restore <name> to bring one back or nikki archive purge <name> to delete it.")
+	case "restore":
+		if err := src.RestoreMetric(store, name); err != nil {
+			return err
+		}
+		fmt.Printf("Restored %v.\n", name)
+	case "purge":
+		fmt.Printf("This deletes every value tracked for %v. Type its name to confirm: ", name)
+		reader := bufio.NewReader(os.Stdin)
+		confirm, _ := reader.ReadString('\n')
+		if strings.TrimSpace(confirm) != name {
+			return fmt.Errorf("not purging %v", name)
+		}
+		if err := src.PurgeMetric(store, name); err != nil {
+			return err
+		}
+		fmt.Printf("Purged %v.\n", name)
+	default:
+		return fmt.Errorf("unknown archive command %q, use restore or purge", action)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package src
+
+import "fmt"
+
+// ########################
+// ### ARCHIVED METRICS ###
+// ########################
+
+// ArchivedMetric is a metric that is hidden from tabs and the entry form
+// but whose values are still kept around.
+type ArchivedMetric struct {
+	Name string
+	Days int // how many days have a value
+}
+
+// ArchivedMetrics syncs the data with the config and lists the archived metrics.
+func ArchivedMetrics(store Store) ([]ArchivedMetric, error) {
+	data, _, err := syncMetrics(store)
+	if err != nil {
+		return nil, err
+	}
+	archived := []ArchivedMetric{}
+	for _, metric := range data.Metrics {
+		if metric.Archived {
+			dates, _ := data.series(metric.key())
+			archived = append(archived, ArchivedMetric{Name: metric.Name, Days: len(dates)})
+		}
+	}
+	return archived, nil
+}
+
+// RestoreMetric brings an archived metric back, either by flipping its
+// archived flag in the config or by adding it to the config again.
+func RestoreMetric(store Store, name string) error {
+	data, _, err := syncMetrics(store)
+	if err != nil {
+		return err
+	}
+	metric, err := findArchived(data, name)
+	if err != nil {
+		return err
+	}
+
+	restored := false
+	for idx, element := range ReadConfig().Metrics {
+		if element.ID == metric.ID {
+			if err := setMetricConfigValue(idx, "archived", "false"); err != nil {
+				return err
+			}
+			restored = true
+		}
+	}
+	if !restored {
+		metric.Archived = false
+		if err := appendMetricConfig(metric); err != nil {
+			return err
+		}
+	}
+	_, _, err = syncMetrics(store)
+	return err
+}
+
+// PurgeMetric deletes an archived metric and every value tracked for it.
+func PurgeMetric(store Store, name string) error {
+	data, _, err := syncMetrics(store)
+	if err != nil {
+		return err
+	}
+	metric, err := findArchived(data, name)
+	if err != nil {
+		return err
+	}
+	for _, element := range ReadConfig().Metrics {
+		if element.ID == metric.ID {
+			return fmt.Errorf("%v is still in the config, remove it there first", name)
+		}
+	}
+
+	data = deleteMetrics(data, []string{metric.key()})
+	metrics := []Metric{}
+	for _, element := range data.Metrics {
+		if element.ID != metric.ID {
+			metrics = append(metrics, element)
+		}
+	}
+	data.Metrics = metrics
+	return store.Save(data)
+}
+
+func findArchived(data EntryData, name string) (Metric, error) {
+	for _, metric := range data.Metrics {
+		if metric.Name == name {
+			if !metric.Archived {
+				return metric, fmt.Errorf("%v is not archived", name)
+			}
+			return metric, nil
+		}
+	}
+	return Metric{}, fmt.Errorf("there is no metric called %v", name)
+}
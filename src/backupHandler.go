@@ -0,0 +1,162 @@
+package src
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ###########################
+// ### SAFE SAVING/BACKUPS ###
+// ###########################
+
+const defaultBackups = 5
+
+const backupTimeFormat = "20060102-150405.000"
+
+// writes to a temp file next to path, syncs it and renames it over path,
+// so a crash leaves either the old or the new file but never half of one
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// only does something if we bail out before the rename
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	// make the rename itself durable
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+func backupDir() string {
+	return filepath.Join(filepath.Dir(storePath()), "backups")
+}
+
+// how many backups to keep, from [general] Backups
+func backupCount() int {
+	cfg := ReadConfig()
+	if cfg.General.Backups == nil {
+		return defaultBackups
+	}
+	return *cfg.General.Backups
+}
+
+// copies the data file at path into the backup dir and drops the oldest
+// backups so only keep of them are left
+func backupData(path string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		// nothing to back up yet
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(backupDir(), 0755); err != nil {
+		return err
+	}
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(filepath.Base(path), ext)
+	name := base + "-" + time.Now().Format(backupTimeFormat) + ext
+	if err := writeFileAtomic(filepath.Join(backupDir(), name), content, 0644); err != nil {
+		return err
+	}
+
+	// data.json and data.db share the dir, only prune backups of this file
+	backups, err := listBackups(path)
+	if err != nil {
+		return err
+	}
+	for len(backups) > keep {
+		if err := os.Remove(filepath.Join(backupDir(), backups[0])); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// ListBackups returns the backups of the data file, oldest first.
+func ListBackups() ([]string, error) {
+	return listBackups(storePath())
+}
+
+// the backups of the file at path, oldest first
+func listBackups(path string) ([]string, error) {
+	entries, err := os.ReadDir(backupDir())
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	ext := filepath.Ext(path)
+	prefix := strings.TrimSuffix(filepath.Base(path), ext) + "-"
+	backups := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		backups = append(backups, name)
+	}
+	// timestamps sort lexically
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// RestoreBackup rolls the data file back to the given backup, which is
+// either its file name or its number as printed by nikki restore.
+// The current data file is backed up first, so a restore can be undone.
+func RestoreBackup(which string) error {
+	backups, err := ListBackups()
+	if err != nil {
+		return err
+	}
+	name := which
+	if n, err := strconv.Atoi(which); err == nil {
+		if n < 1 || n > len(backups) {
+			return fmt.Errorf("there is no backup number %d", n)
+		}
+		name = backups[n-1]
+	} else if !contains(backups, which) {
+		return fmt.Errorf("there is no backup called %v", which)
+	}
+
+	content, err := os.ReadFile(filepath.Join(backupDir(), name))
+	if err != nil {
+		return err
+	}
+	// keep one more than usual so the oldest backup survives the restore
+	if err := backupData(storePath(), max(backupCount(), len(backups))+1); err != nil {
+		return err
+	}
+	return writeFileAtomic(storePath(), content, 0644)
+}
@@ -1,29 +1,48 @@
 package src
 
 import (
+	"fmt"
 	toml "github.com/naoina/toml"
 	"os"
+	"strconv"
+	"strings"
 )
 
 type General struct {
 	BorderColor       string
 	ActiveButtonColor string
 	ButtonColor       string
+	Backups           *int   // how many old versions of the data file to keep
+	Storage           string // "json" (default) or "sqlite"
 }
 
 type Config struct {
 	General General
-	Metrics []struct {
-		Name   string
-		Color1 string
-		Color2 string
-		Rule   string
+	Metrics []Metric
+}
+
+// a config value that can be written as 40 as well as 40.5
+type number float64
+
+func (n *number) UnmarshalTOML(decode func(interface{}) error) error {
+	var value interface{}
+	if err := decode(&value); err != nil {
+		return err
 	}
+	switch value := value.(type) {
+	case int64:
+		*n = number(value)
+	case float64:
+		*n = number(value)
+	default:
+		return fmt.Errorf("%v is not a number", value)
+	}
+	return nil
 }
 
 func ReadConfig() Config {
 	var cfg Config
-	config, err := os.Open("config.toml")
+	config, err := os.Open(configPath)
 	if err != nil {
 		panic(err)
 	}
@@ -35,36 +54,127 @@ func ReadConfig() Config {
 	return cfg
 }
 
-func checkConfig() (EntryData, []string, map[int][]string, []string) {
+func checkConfig(data EntryData) (EntryData, []string, []Metric, []string, error) {
 	cfg := ReadConfig()
+	for _, element := range cfg.Metrics {
+		if element.Archived {
+			continue
+		}
+		if _, err := element.rule(); err != nil {
+			return data, nil, nil, nil, fmt.Errorf("config: %v", err)
+		}
+		if _, _, err := element.goal(); err != nil {
+			return data, nil, nil, nil, fmt.Errorf("config: %v", err)
+		}
+		if err := element.checkDirection(); err != nil {
+			return data, nil, nil, nil, fmt.Errorf("config: %v", err)
+		}
+		if _, _, err := element.schedule(data); err != nil {
+			return data, nil, nil, nil, fmt.Errorf("config: %v", err)
+		}
+		if _, _, err := element.target(); err != nil {
+			return data, nil, nil, nil, fmt.Errorf("config: %v", err)
+		}
+		if err := element.checkGrace(); err != nil {
+			return data, nil, nil, nil, fmt.Errorf("config: %v", err)
+		}
+	}
+	if err := checkDerived(cfg.Metrics); err != nil {
+		return data, nil, nil, nil, fmt.Errorf("config: %v", err)
+	}
+	if err := assignMetricIDs(&cfg, data); err != nil {
+		return data, nil, nil, nil, err
+	}
 	//fmt.Printf("metrics: %v\n", cfg.Metrics)
 	metrics := []string{}
-	data := loadJSON()
-	for _, element := range data.Data {
-		metrics = append(metrics, element.Name)
+	for _, metric := range data.Metrics {
+		if !metric.Archived {
+			metrics = append(metrics, metric.Name)
+		}
 	}
 	// first check whether metrics have changed
 	// Handle: only removed metrics, only added metrics,
 	// added and removed metrics
 
 	//update the decoded data based on changes in config!
-	updatedMetrics := make(map[int][]string, len(cfg.Metrics))
+	// active metrics come first, in config order, so their index in
+	// data.Metrics is also their tab/input index. Derived ones have no
+	// input and follow the entered ones.
+	updatedMetrics := []Metric{}
+	derivedMetrics := []Metric{}
+	archivedMetrics := []Metric{}
 	updatedMetricsNames := []string{}
-	newMetricNames := []string{}
-	for index, element := range cfg.Metrics {
-		updatedMetrics[index] = []string{element.Name, element.Rule, element.Color1, element.Color2}
+	configIDs := map[int]bool{}
+	for _, element := range cfg.Metrics {
+		configIDs[element.ID] = true
+		if element.Archived {
+			archivedMetrics = append(archivedMetrics, element)
+			continue
+		}
+		if element.derived() {
+			derivedMetrics = append(derivedMetrics, element)
+			continue
+		}
+		updatedMetrics = append(updatedMetrics, element)
 		updatedMetricsNames = append(updatedMetricsNames, element.Name)
-		if !contains(metrics, element.Name) {
-			newMetricNames = append(newMetricNames, element.Name)
+	}
+	for _, element := range derivedMetrics {
+		updatedMetrics = append(updatedMetrics, element)
+		updatedMetricsNames = append(updatedMetricsNames, element.Name)
+	}
+	// metrics dropped from the config keep their data, just hidden
+	for _, element := range data.Metrics {
+		if !configIDs[element.ID] {
+			element.Archived = true
+			archivedMetrics = append(archivedMetrics, element)
 		}
 	}
-	for _, element := range metrics {
-		if !contains(updatedMetricsNames, element) {
-			newMetricNames = append(newMetricNames, element)
+	updatedMetrics = append(updatedMetrics, archivedMetrics...)
+
+	return data, metrics, updatedMetrics, updatedMetricsNames, nil
+}
+
+// gives every [[metrics]] entry without an id one and writes it back to the
+// config. New entries pick up the ID of a stored metric with the same name,
+// so existing data carries over the first time IDs are assigned.
+func assignMetricIDs(cfg *Config, data EntryData) error {
+	nextID := 1
+	claimed := map[int]bool{}
+	for _, metric := range data.Metrics {
+		nextID = max(nextID, metric.ID+1)
+	}
+	for _, element := range cfg.Metrics {
+		if element.ID == 0 {
+			continue
+		}
+		if claimed[element.ID] {
+			return fmt.Errorf("config: more than one metric has id = %d", element.ID)
 		}
+		claimed[element.ID] = true
+		nextID = max(nextID, element.ID+1)
 	}
 
-	return data, metrics, updatedMetrics, updatedMetricsNames
+	for idx, element := range cfg.Metrics {
+		if element.ID != 0 {
+			continue
+		}
+		for _, metric := range data.Metrics {
+			if metric.Name == element.Name && !claimed[metric.ID] {
+				element.ID = metric.ID
+				break
+			}
+		}
+		if element.ID == 0 {
+			element.ID = nextID
+			nextID++
+		}
+		claimed[element.ID] = true
+		cfg.Metrics[idx].ID = element.ID
+		if err := setMetricConfigValue(idx, "id", strconv.Itoa(element.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func checkForConfigChanges(updatedMetrics []string, metrics []string) bool {
@@ -79,32 +189,75 @@ func checkForConfigChanges(updatedMetrics []string, metrics []string) bool {
 	return false
 }
 
-func checkForDeletedMetrics(metrics []string, newMetrics []string) (bool, []string) {
-	change := false
-	deletedMetrics := []string{}
-	for _, metric := range metrics {
-		if !contains(newMetrics, metric) {
-			change = true
-			deletedMetrics = append(deletedMetrics, metric)
+// ######################
+// ### CONFIG WRITING ###
+// ######################
+
+// config changes are made line by line so comments and formatting survive
+
+// line ranges [start, end) of all [[metrics]] blocks, in config order
+func metricBlocks(lines []string) [][2]int {
+	blocks := [][2]int{}
+	for idx, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "[") {
+			continue
+		}
+		if len(blocks) > 0 && blocks[len(blocks)-1][1] == -1 {
+			blocks[len(blocks)-1][1] = idx
 		}
+		if strings.HasPrefix(trimmed, "[[metrics]]") {
+			blocks = append(blocks, [2]int{idx, -1})
+		}
+	}
+	if len(blocks) > 0 && blocks[len(blocks)-1][1] == -1 {
+		blocks[len(blocks)-1][1] = len(lines)
 	}
-	return change, deletedMetrics
+	return blocks
 }
 
-func checkForAddedMetrics(metrics []string, newMetrics []string) (bool, []string) {
-	change := false
-	addedMetrics := []string{}
-	for _, metric := range newMetrics {
-		found := false
-		for _, metric2 := range metrics {
-			if metric2 == metric {
-				found = true
-			}
+// sets key = value in the index-th [[metrics]] block of the config.
+// value has to be valid TOML already, e.g. quoted if it is a string
+func setMetricConfigValue(index int, key string, value string) error {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(content), "\n")
+	blocks := metricBlocks(lines)
+	if index < 0 || index >= len(blocks) {
+		return fmt.Errorf("config has no metric number %d", index+1)
+	}
+	start, end := blocks[index][0], blocks[index][1]
+
+	indent := "    "
+	last := start
+	for idx := start + 1; idx < end; idx++ {
+		trimmed := strings.TrimSpace(lines[idx])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
 		}
-		if !found {
-			change = true
-			addedMetrics = append(addedMetrics, metric)
+		last = idx
+		indent = lines[idx][:len(lines[idx])-len(strings.TrimLeft(lines[idx], " \t"))]
+		name, _, found := strings.Cut(trimmed, "=")
+		if found && strings.EqualFold(strings.TrimSpace(name), key) {
+			lines[idx] = indent + key + " = " + value
+			return writeFileAtomic(configPath, []byte(strings.Join(lines, "\n")), 0644)
 		}
 	}
-	return change, addedMetrics
+	// not set yet, add it after the last key of the block
+	lines = append(lines[:last+1], append([]string{indent + key + " = " + value}, lines[last+1:]...)...)
+	return writeFileAtomic(configPath, []byte(strings.Join(lines, "\n")), 0644)
+}
+
+// adds a [[metrics]] block for metric to the end of the config
+func appendMetricConfig(metric Metric) error {
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	block := fmt.Sprintf("\n[[metrics]]\n    id = %d\n    name = %q\n    color1 = %q\n    color2 = %q\n    rule = %q\n",
+		metric.ID, metric.Name, metric.Color1, metric.Color2, metric.Rule)
+	content = append([]byte(strings.TrimRight(string(content), "\n")+"\n"), block...)
+	return writeFileAtomic(configPath, content, 0644)
 }
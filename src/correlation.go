@@ -0,0 +1,281 @@
+package src
+
+import (
+	"fmt"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/lucasb-eyer/go-colorful"
+	"math"
+	"strings"
+)
+
+// ##################
+// ## CORRELATIONS ##
+// ##################
+
+// fewer days than this in common and there is nothing to say
+const minPairs = 3
+
+// the metrics that can be correlated, by index into data.Metrics. Choices
+// have no order, so they are left out.
+func numericMetrics(data EntryData) []int {
+	metrics := []int{}
+	for idx, metric := range data.Metrics {
+		if metric.Archived || metric.isChoice() {
+			continue
+		}
+		if _, err := metric.rule(); err != nil {
+			continue
+		}
+		metrics = append(metrics, idx)
+	}
+	return metrics
+}
+
+// the values of x lag days before each day y has a value on, paired with
+// that value of y. With a lag of 1 it is e.g. yesterday's bedtime against
+// today's mood.
+func pairedValues(data EntryData, x int, y int, lag int) ([]float64, []float64) {
+	xValues := numericSeries(data, x)
+	yValues := numericSeries(data, y)
+	xs, ys := []float64{}, []float64{}
+	dates, _ := data.series(data.Metrics[y].key())
+	for _, date := range dates {
+		yValue, ok := yValues[dayKey(date)]
+		if !ok {
+			continue
+		}
+		if xValue, ok := xValues[dayKey(date.AddDate(0, 0, -lag))]; ok {
+			xs = append(xs, xValue)
+			ys = append(ys, yValue)
+		}
+	}
+	return xs, ys
+}
+
+// the metric's values as numbers by day, times wrapped like in its stats
+func numericSeries(data EntryData, metric int) map[string]float64 {
+	series := map[string]float64{}
+	rule, err := data.Metrics[metric].rule()
+	if err != nil {
+		return series
+	}
+	dates, values := data.series(data.Metrics[metric].key())
+	rule = statsRule(rule, values)
+	for idx, value := range values {
+		if number, err := rule.Parse(value); err == nil {
+			series[dayKey(dates[idx])] = number
+		}
+	}
+	return series
+}
+
+// Pearson's r of the pairs, ok is false if there are too few of them or
+// one side never changes
+func correlation(xs []float64, ys []float64) (float64, bool) {
+	if len(xs) < minPairs {
+		return 0, false
+	}
+	n := float64(len(xs))
+	xMean, yMean := 0.0, 0.0
+	for idx := range xs {
+		xMean += xs[idx] / n
+		yMean += ys[idx] / n
+	}
+	cov, xVar, yVar := 0.0, 0.0, 0.0
+	for idx := range xs {
+		cov += (xs[idx] - xMean) * (ys[idx] - yMean)
+		xVar += (xs[idx] - xMean) * (xs[idx] - xMean)
+		yVar += (ys[idx] - yMean) * (ys[idx] - yMean)
+	}
+	if xVar == 0 || yVar == 0 {
+		return 0, false
+	}
+	r := cov / math.Sqrt(xVar*yVar)
+	if math.Abs(r) < 0.005 {
+		// shows as 0.00 rather than -0.00
+		r = 0
+	}
+	return r, true
+}
+
+// from red for going against each other to green for going together
+func correlationColor(r float64) string {
+	neutral, _ := colorful.Hex("#928374")
+	if r < 0 {
+		against, _ := colorful.Hex("#fb4934")
+		return neutral.BlendLuv(against, -r).Hex()
+	}
+	together, _ := colorful.Hex("#b8bb26")
+	return neutral.BlendLuv(together, r).Hex()
+}
+
+// the name of a row metric, with the day it is taken from
+func lagLabel(name string, lag int) string {
+	if lag == 1 {
+		return name + " (yesterday)"
+	}
+	return name
+}
+
+func correlationView(m model) string {
+	var (
+		dialogBoxStyle = lipgloss.NewStyle().
+				Border(lipgloss.RoundedBorder()).
+				BorderForeground(lipgloss.Color(m.generalConfig.BorderColor)).
+				Padding(1, 2)
+		selected = lipgloss.NewStyle().Background(lipgloss.Color(m.generalConfig.ActiveButtonColor))
+	)
+	metrics := numericMetrics(m.data)
+	if len(metrics) < 2 {
+		return dialogBoxStyle.Render("There have to be at least two metrics with numbers to correlate.") +
+			"\n\nPress b to return to the menu.\nPress q to quit."
+	}
+	labelWidth := 0
+	for _, metric := range metrics {
+		labelWidth = max(labelWidth, lipgloss.Width(lagLabel(m.data.Metrics[metric].Name, m.corrLag)))
+	}
+
+	// rows are taken lag days before the columns
+	b := strings.Builder{}
+	b.WriteString(strings.Repeat(" ", labelWidth+5))
+	for col := range metrics {
+		b.WriteString(fmt.Sprintf("%6d ", col+1))
+	}
+	b.WriteRune('\n')
+	for row, x := range metrics {
+		b.WriteString(fmt.Sprintf("%2d  %-*s ", row+1, labelWidth, lagLabel(m.data.Metrics[x].Name, m.corrLag)))
+		for col, y := range metrics {
+			cell := lipgloss.NewStyle().Foreground(lipgloss.Color("#928374")).Render(fmt.Sprintf("%6s", "·"))
+			if x == y && m.corrLag == 0 {
+				cell = lipgloss.NewStyle().Foreground(lipgloss.Color("#928374")).Render(fmt.Sprintf("%6s", "—"))
+			} else if r, ok := correlation(pairedValues(m.data, x, y, m.corrLag)); ok {
+				cell = lipgloss.NewStyle().Foreground(lipgloss.Color(correlationColor(r))).Render(fmt.Sprintf("%+6.2f", r))
+			}
+			if row == m.corrRow && col == m.corrCol {
+				cell = selected.Render(cell)
+			}
+			b.WriteString(cell + " ")
+		}
+		b.WriteRune('\n')
+	}
+
+	x, y := metrics[m.corrRow], metrics[m.corrCol]
+	xs, ys := pairedValues(m.data, x, y, m.corrLag)
+	summary := fmt.Sprintf("%v vs %v:  ", lagLabel(m.data.Metrics[x].Name, m.corrLag), m.data.Metrics[y].Name)
+	if r, ok := correlation(xs, ys); ok {
+		summary += fmt.Sprintf("r = %+.2f over %d days", r, len(xs))
+	} else {
+		summary += fmt.Sprintf("not enough to go on (%d days)", len(xs))
+	}
+	b.WriteString("\n" + summary)
+
+	s := dialogBoxStyle.Render(b.String())
+	if m.showScatter {
+		s += "\n" + dialogBoxStyle.Render(scatterView(m.data, x, y, xs, ys, m.corrLag))
+	}
+
+	// The footer
+	s += "\n\nPress the arrow keys or h, j, k and l to pick a pair, enter to plot it."
+	s += "\nPress y to compare with the day before, b to return to the menu.\nPress q to quit."
+	return s
+}
+
+// a plot of the pairs, each cell shaded by how many fall into it
+func scatterView(data EntryData, x int, y int, xs []float64, ys []float64, lag int) string {
+	const plotWidth, plotHeight = 48, 14
+	if len(xs) == 0 {
+		return "No days to plot."
+	}
+	xMin, xMax := span(xs)
+	yMin, yMax := span(ys)
+	counts := make([][]int, plotHeight)
+	for row := range counts {
+		counts[row] = make([]int, plotWidth)
+	}
+	for idx := range xs {
+		col := int(math.Round((xs[idx] - xMin) / (xMax - xMin) * (plotWidth - 1)))
+		row := plotHeight - 1 - int(math.Round((ys[idx]-yMin)/(yMax-yMin)*(plotHeight-1)))
+		counts[row][col]++
+	}
+
+	xRule, _ := data.Metrics[x].rule()
+	yRule, _ := data.Metrics[y].rule()
+	xRule = statsRule(xRule, valuesOf(data, x))
+	yRule = statsRule(yRule, valuesOf(data, y))
+	yTop, yBottom := yRule.Format(yMax), yRule.Format(yMin)
+	axisWidth := max(lipgloss.Width(yTop), lipgloss.Width(yBottom))
+
+	b := strings.Builder{}
+	b.WriteString(data.Metrics[y].Name + "\n")
+	for row := range counts {
+		label := ""
+		if row == 0 {
+			label = yTop
+		} else if row == plotHeight-1 {
+			label = yBottom
+		}
+		b.WriteString(fmt.Sprintf("%*s │", axisWidth, label))
+		for _, count := range counts[row] {
+			switch {
+			case count == 0:
+				b.WriteRune(' ')
+			case count == 1:
+				b.WriteRune('·')
+			case count < 4:
+				b.WriteRune('•')
+			default:
+				b.WriteRune('●')
+			}
+		}
+		b.WriteRune('\n')
+	}
+	b.WriteString(strings.Repeat(" ", axisWidth+1) + "└" + strings.Repeat("─", plotWidth) + "\n")
+	xLeft, xRight := xRule.Format(xMin), xRule.Format(xMax)
+	b.WriteString(strings.Repeat(" ", axisWidth+2) + xLeft +
+		strings.Repeat(" ", max(1, plotWidth-lipgloss.Width(xLeft)-lipgloss.Width(xRight))) + xRight + "\n")
+	b.WriteString(strings.Repeat(" ", axisWidth+2) + lagLabel(data.Metrics[x].Name, lag))
+	return b.String()
+}
+
+// the lowest and highest value, a little apart if they are the same
+func span(values []float64) (float64, float64) {
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		low = math.Min(low, value)
+		high = math.Max(high, value)
+	}
+	if low == high {
+		return low - 1, high + 1
+	}
+	return low, high
+}
+
+func valuesOf(data EntryData, metric int) []string {
+	_, values := data.series(data.Metrics[metric].key())
+	return values
+}
+
+func updateCorrelation(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		count := len(numericMetrics(m.data))
+		switch msg.String() {
+		case "up", "k":
+			m.corrRow = (m.corrRow - 1 + count) % max(count, 1)
+		case "down", "j":
+			m.corrRow = (m.corrRow + 1) % max(count, 1)
+		case "left", "h":
+			m.corrCol = (m.corrCol - 1 + count) % max(count, 1)
+		case "right", "l":
+			m.corrCol = (m.corrCol + 1) % max(count, 1)
+		case "enter":
+			m.showScatter = !m.showScatter
+		case "y":
+			m.corrLag = 1 - m.corrLag
+		case "b":
+			m.chosen = false
+		}
+	}
+	return m, nil
+}
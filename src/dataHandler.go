@@ -7,6 +7,9 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"log"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -14,65 +17,61 @@ import (
 // ### DATA STORAGE FUNCTIONALITY ###
 // ##################################
 
-type MetricData struct {
-	Name   string
-	Date   []time.Time
-	Value  []string
-	Color1 string
-	Color2 string
+// current layout of data.json, bump it and register a migration when changing EntryData
+const dataVersion = 5
+
+// a tracked metric as defined in the [[metrics]] section of the config.
+// Values are stored under its ID, so everything else about it can change.
+type Metric struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Rule     string `json:"rule"`
+	Color1   string `json:"color1"`
+	Color2   string `json:"color2"`
+	Archived bool   `json:"archived,omitempty"` // hidden, but its data is kept
+
+	// options below only live in the config
+	Required  bool     `json:"-"` // the entry form won't take the day without it
+	Goal      string   `json:"-"` // target like ">= 8", see parseGoal
+	Decimals  *int     `json:"-"` // digits after the point for float metrics
+	Min       *number  `json:"-"` // lowest value allowed
+	Max       *number  `json:"-"` // highest value allowed
+	Choices   []string `json:"-"` // options of a choice metric
+	Colors    []string `json:"-"` // one color per choice
+	Counts    []string `json:"-"` // choices that count as done, all but the first by default
+	Wrap      string   `json:"-"` // when a time metric's day starts, e.g. "18:00"
+	Expr      string   `json:"-"` // computes a derived metric, see derived.go
+	Alias     string   `json:"-"` // short name to use in exprs
+	Direction string   `json:"-"` // "higher" (default) or "lower" is better
+	Kind      string   `json:"-"` // "avoid" for habits to stay away from
+	Schedule  string   `json:"-"` // when it is due, e.g. "mon wed fri", see parseSchedule
+	Target    string   `json:"-"` // how often per week or month, e.g. "3 per week"
+	Grace     int      `json:"-"` // missed days a streak gets over
 }
 
-type EntryData struct {
-	Metrics map[int][]string
-	Data    []MetricData
-}
-
-type TestData struct {
-	Date  int
-	Value string
-}
-
-func decodeJson() {
-
-	var result EntryData
-	jsonFile, err := os.ReadFile("test.json")
-	if err != nil {
-		panic(nil)
+// the part of a metric that is kept with the data
+func (metric Metric) stored() Metric {
+	return Metric{
+		ID:       metric.ID,
+		Name:     metric.Name,
+		Rule:     metric.Rule,
+		Color1:   metric.Color1,
+		Color2:   metric.Color2,
+		Archived: metric.Archived,
 	}
-
-	json.Unmarshal(jsonFile, &result)
-
 }
 
-func encodeJson() {
-
-	sleepData := MetricData{
-		Name:  "Got up",
-		Date:  []time.Time{time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 4, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 6, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 7, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 8, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 9, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 11, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 12, 0, 0, 0, 0, time.UTC)},
-		Value: []string{"06:00", "07:30", "08:20", "12:30", "06:00", "06:00", "06:00", "06:00", "08:45", "11:20"},
-	}
-
-	moodData := MetricData{
-		Name:  "Mood",
-		Date:  []time.Time{time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 4, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 6, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 7, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 8, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 9, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 11, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 12, 0, 0, 0, 0, time.UTC)},
-		Value: []string{"10", "6", "6", "6", "6", "8", "8", "7", "8", "8"},
-	}
-
-	bundledData := []MetricData{
-		sleepData, moodData,
-	}
-
-	data := EntryData{
-		Metrics: map[int][]string{0: []string{"Sleep", "time"}, 1: []string{"Mood", "int10"}},
-		Data:    bundledData,
-	}
+// marks a metric as deliberately skipped on a day, e.g. a rest day,
+// as opposed to not having any data for it
+const skipped = "-"
 
-	var jsonData []byte
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		log.Println(err)
-	}
-	_ = os.WriteFile("test.json", jsonData, 0644)
+// one map of metric ID to value per tracked day, keyed like "2023-01-17",
+// and the diary note written that day under the same key
+type EntryData struct {
+	Version int                          `json:"version"`
+	Metrics []Metric                     `json:"metrics"`
+	Days    map[string]map[string]string `json:"days"`
+	Notes   map[string]string            `json:"notes"`
 }
 
 // stores JSON
@@ -83,21 +82,59 @@ func storeJSON(data EntryData) int {
 		log.Println(err)
 		return 1
 	}
-	fmt.Println("Saving data")
-	_ = os.WriteFile("data.json", jsonData, 0644)
+	// stderr, so it stays out of exports
+	fmt.Fprintln(os.Stderr, "Saving data")
+	if err := backupData(dataPath, backupCount()); err != nil {
+		log.Println(err)
+		return 1
+	}
+	if err := writeFileAtomic(dataPath, jsonData, 0644); err != nil {
+		log.Println(err)
+		return 1
+	}
 	return 0
 }
 
 // loads JSON
-func loadJSON() EntryData {
+func loadJSON() (EntryData, error) {
 	var result EntryData
-	jsonFile, err := os.ReadFile("data.json")
+	jsonFile, err := os.ReadFile(dataPath)
+	if os.IsNotExist(err) {
+		// first run, nothing tracked yet
+		return newEntryData(), nil
+	} else if err != nil {
+		return result, err
+	}
+
+	jsonFile, migrated, err := migrateData(jsonFile)
 	if err != nil {
-		panic(nil)
+		return result, err
+	}
+	if err := json.Unmarshal(jsonFile, &result); err != nil {
+		return result, err
+	}
+	if result.Metrics == nil {
+		result.Metrics = []Metric{}
+	}
+	if result.Days == nil {
+		result.Days = map[string]map[string]string{}
+	}
+	if result.Notes == nil {
+		result.Notes = map[string]string{}
 	}
+	if migrated && storeJSON(result) != 0 {
+		return result, fmt.Errorf("couldn't save migrated %v", dataPath)
+	}
+	return result, nil
+}
 
-	json.Unmarshal(jsonFile, &result)
-	return result
+func newEntryData() EntryData {
+	return EntryData{
+		Version: dataVersion,
+		Metrics: []Metric{},
+		Days:    map[string]map[string]string{},
+		Notes:   map[string]string{},
+	}
 }
 
 // #######################################
@@ -107,29 +144,24 @@ func loadJSON() EntryData {
 // writes entry data into JSON
 func addEntry(file EntryData) EntryData {
 	// need temporary storage for new entry
-	tempStorage := EntryData{
-		Metrics: file.Metrics,
-		Data:    []MetricData{},
-	}
+	values := map[string]string{}
 
-	for metric, _ := range file.Metrics {
+	for _, metric := range file.Metrics {
 
 		inputCheck := true
 		for inputCheck == true {
 			var input string
-			fmt.Printf("Please input todays data for metric %v\n", file.Metrics[metric][0])
+			fmt.Printf("Please input todays data for metric %v\n", metric.Name)
 			// get input
 			fmt.Scan(&input)
 			// check if input is valid
-			ok := ruleChecker(input, file.Metrics[metric][1])
-			if ok {
-				// create new MetricData var
-				tempData := MetricData{
-					Date:  []time.Time{time.Now()},
-					Value: []string{input},
-				}
-				// add it to temp storage
-				tempStorage.Data = append(tempStorage.Data, tempData)
+			rule, err := metric.rule()
+			if err != nil {
+				fmt.Println(err)
+				break
+			}
+			if rule.Validate(input) {
+				values[metric.key()] = input
 				inputCheck = false
 			} else {
 				// no bueno
@@ -137,16 +169,15 @@ func addEntry(file EntryData) EntryData {
 			}
 		}
 	}
-	// append temp storage to actual data
-	for index, _ := range file.Data {
-		file.Data[index].Date = append(file.Data[index].Date, tempStorage.Data[index].Date...)
-		file.Data[index].Value = append(file.Data[index].Value, tempStorage.Data[index].Value...)
-	}
+	file.setDay(time.Now(), values)
 	return file
 }
 
 func newEntry() {
-	file := loadJSON()
+	file, err := loadJSON()
+	if err != nil {
+		panic(err)
+	}
 	// check if there is an entry for today already
 	file = addEntry(file)
 	storeJSON(file)
@@ -154,15 +185,26 @@ func newEntry() {
 
 func updateEntry(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 
-	var (
-		focusedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(m.generalConfig.ActiveButtonColor))
-		noStyle      = lipgloss.NewStyle()
-	)
 	switch msg := msg.(type) {
 
 	// Is it a key press?
 	case tea.KeyMsg:
 
+		// the note takes any text, only tab and shift+tab leave it
+		if m.typingNote() && msg.String() != "tab" && msg.String() != "shift+tab" {
+			return m, m.updateInputs(msg)
+		}
+
+		// picked fields take single keys instead of text
+		picker, picking := m.focusedPicker()
+		if picking {
+			input := &m.inputs[m.focusIndex-1]
+			if value, ok := pickValue(picker, input.Value(), msg.String()); ok {
+				input.SetValue(value)
+				return m, nil
+			}
+		}
+
 		// Cool, what was the actual key pressed?
 		switch msg.String() {
 
@@ -171,44 +213,73 @@ func updateEntry(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		case "b":
 			m.chosen = false
+		case "left", "right":
+			// step through the days while the date is focused
+			if m.focusIndex == 0 && m.commitTypedDate() {
+				day := m.entryDate.AddDate(0, 0, -1)
+				if msg.String() == "right" {
+					day = m.entryDate.AddDate(0, 0, 1)
+				}
+				if !day.After(time.Now()) {
+					m.loadEntryDate(day)
+				}
+				return m, nil
+			}
 		case "tab", "shift+tab", "enter", "up", "down":
 			s := msg.String()
 
+			// a typed date counts once the date field is left
+			if m.focusIndex == 0 && !m.commitTypedDate() {
+				return m, nil
+			}
+
 			// Did the user press enter while the submit button was focused?
 			// If so, exit.
-			if s == "enter" && m.focusIndex == len(m.inputs) {
-				// Validate inputs
+			if s == "enter" && m.focusIndex == m.submitIndex() {
+				// Validate inputs, empty and skipped fields are fine unless required
 				inputValid := true
 				for i, e := range m.inputs {
-					rule := m.data.Metrics[i][1]
-					if ruleChecker(e.Value(), rule) == false {
+					metric := m.data.Metrics[i]
+					value := strings.TrimSpace(e.Value())
+					if value == skipped || (value == "" && !metric.Required) {
+						continue
+					}
+					rule, err := metric.rule()
+					if err == nil && value == "" {
+						err = fmt.Errorf("it is required")
+					} else if err == nil {
+						_, err = rule.Parse(value)
+					}
+					if err != nil {
 						inputValid = false
 						m.wrongIndex = i
+						m.wrongReason = err.Error()
 					}
 				}
 				if inputValid == true {
+					values := map[string]string{}
 					for idx, ele := range m.inputs {
-						// Check if entry for that day exists, replace it or just store
-						if checkIfEntryExists(m.data, idx, time.Now()) == true {
-							m.data.Data[idx].Date[len(m.data.Data[idx].Date)-1] = time.Now()
-							m.data.Data[idx].Value[len(m.data.Data[idx].Value)-1] = ele.Value()
-
-						} else {
-							m.data.Data[idx].Date = append(m.data.Data[idx].Date, time.Now())
-							m.data.Data[idx].Value = append(m.data.Data[idx].Value, ele.Value())
-						}
+						// empty fields clear what was there, nothing is made up
+						metric := m.data.Metrics[idx]
+						values[metric.key()] = metric.normalize(strings.TrimSpace(ele.Value()))
 					}
 					m.wrongInput = false
-					res := storeJSON(m.data)
-					if res != 0 {
-						panic("wwww")
+					// replaces that days entry if there is one already,
+					// the form stays open if that didn't work out
+					if err := m.store.PutEntry(m.entryDate, values, m.noteInput.Value()); err != nil {
+						m.saveError = err.Error()
+						return m, nil
 					}
-					// TODO: reset the complete view component
+					m.saveError = ""
+					m.data.setDay(m.entryDate, values)
+					m.data = deriveMetrics(m.data)
+					m.data.setNote(m.entryDate, m.noteInput.Value())
 
 					// return to menu
 					m.chosen = false
 				} else {
 					m.wrongInput = true
+					m.saveError = ""
 				}
 
 				return m, nil
@@ -221,28 +292,16 @@ func updateEntry(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.focusIndex++
 			}
 
-			if m.focusIndex > len(m.inputs) {
+			if m.focusIndex > m.submitIndex() {
 				m.focusIndex = 0
 			} else if m.focusIndex < 0 {
-				m.focusIndex = len(m.inputs)
-			}
-
-			cmds := make([]tea.Cmd, len(m.inputs))
-			for i := 0; i <= len(m.inputs)-1; i++ {
-				if i == m.focusIndex {
-					// Set focused state
-					cmds[i] = m.inputs[i].Focus()
-					m.inputs[i].PromptStyle = focusedStyle
-					m.inputs[i].TextStyle = focusedStyle
-					continue
-				}
-				// Remove focused state
-				m.inputs[i].Blur()
-				m.inputs[i].PromptStyle = noStyle
-				m.inputs[i].TextStyle = noStyle
+				m.focusIndex = m.submitIndex()
 			}
 
-			return m, tea.Batch(cmds...)
+			return m, m.focusEntryField()
+		}
+		if picking {
+			return m, nil
 		}
 	}
 	cmd := m.updateInputs(msg)
@@ -250,15 +309,232 @@ func updateEntry(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
-func checkIfEntryExists(data EntryData, metric int, t time.Time) bool {
-	if len(data.Data[metric].Date) < 1 {
-		return false
+// moves the focus to the field at focusIndex
+func (m *model) focusEntryField() tea.Cmd {
+	var (
+		focusedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(m.generalConfig.ActiveButtonColor))
+		noStyle      = lipgloss.NewStyle()
+	)
+	cmds := make([]tea.Cmd, len(m.inputs)+1)
+	if m.focusIndex == 0 {
+		cmds[0] = m.dateInput.Focus()
+		m.dateInput.PromptStyle = focusedStyle
+		m.dateInput.TextStyle = focusedStyle
+	} else {
+		m.dateInput.Blur()
+		m.dateInput.PromptStyle = noStyle
+		m.dateInput.TextStyle = noStyle
+	}
+	for i := 0; i <= len(m.inputs)-1; i++ {
+		if i+1 == m.focusIndex {
+			// Set focused state
+			cmds[i+1] = m.inputs[i].Focus()
+			m.inputs[i].PromptStyle = focusedStyle
+			m.inputs[i].TextStyle = focusedStyle
+			continue
+		}
+		// Remove focused state
+		m.inputs[i].Blur()
+		m.inputs[i].PromptStyle = noStyle
+		m.inputs[i].TextStyle = noStyle
 	}
-	lastEntry := data.Data[metric].Date[len(data.Data[metric].Date)-1]
-	lastDate := lastEntry.Format("02.01.2006")
-	if t.Format("02.01.2006") == lastDate {
-		return true
+	if m.focusIndex == m.noteIndex() {
+		cmds = append(cmds, m.noteInput.Focus())
 	} else {
+		m.noteInput.Blur()
+	}
+	return tea.Batch(cmds...)
+}
+
+// the picker of the focused input, if its metric's values are picked
+func (m model) focusedPicker() (Picker, bool) {
+	if m.focusIndex < 1 || m.focusIndex > len(m.inputs) {
+		return nil, false
+	}
+	rule, err := m.data.Metrics[m.focusIndex-1].rule()
+	if err != nil {
+		return nil, false
+	}
+	picker, ok := rule.(Picker)
+	return picker, ok
+}
+
+// what key does to a picked field, clearing and skipping work for all of them
+func pickValue(picker Picker, current string, key string) (string, bool) {
+	switch key {
+	case "backspace", "delete":
+		return "", true
+	case skipped:
+		return skipped, true
+	}
+	return picker.Pick(current, key)
+}
+
+// the note sits between the inputs and the submit button
+func (m model) noteIndex() int {
+	return len(m.inputs) + 1
+}
+
+func (m model) submitIndex() int {
+	return len(m.inputs) + 2
+}
+
+func (m model) typingNote() bool {
+	return m.chosen && m.cursor1 == 1 && m.focusIndex == m.noteIndex()
+}
+
+// switches the entry form to day and fills in whatever was tracked on it
+func (m *model) loadEntryDate(day time.Time) {
+	m.entryDate = dayStart(day)
+	m.dateInput.SetValue(dayKey(m.entryDate))
+	m.wrongDate = false
+	values, err := m.store.GetDay(m.entryDate)
+	if err != nil {
+		// what was loaded on start is the same unless something else wrote the store
+		values = m.data.day(m.entryDate)
+	}
+	for idx := range m.inputs {
+		metric := m.data.Metrics[idx]
+		m.inputs[idx].SetValue(metric.display(values[metric.key()]))
+	}
+	m.noteInput.SetValue(m.data.note(m.entryDate))
+}
+
+// takes over a date typed into the date field, reports whether it was
+// a day that can be logged
+func (m *model) commitTypedDate() bool {
+	day, err := time.ParseInLocation(dayFormat, strings.TrimSpace(m.dateInput.Value()), time.Local)
+	if err != nil || day.After(time.Now()) {
+		m.wrongDate = true
 		return false
 	}
+	if !sameDay(day, m.entryDate) {
+		m.loadEntryDate(day)
+	}
+	m.wrongDate = false
+	return true
+}
+
+// #########################
+// ### DATE KEYED ACCESS ###
+// #########################
+
+const dayFormat = "2006-01-02"
+
+func dayKey(t time.Time) string {
+	return t.Format(dayFormat)
+}
+
+// local midnight of the day t falls on
+func dayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+func sameDay(a time.Time, b time.Time) bool {
+	return dayKey(a) == dayKey(b)
+}
+
+// the key the metric's values are stored under in each day
+func (metric Metric) key() string {
+	return strconv.Itoa(metric.ID)
+}
+
+// all days that have a value for the metric with the given key, in date order.
+// Skipped days have no value, see skippedDays.
+func (data EntryData) series(metric string) ([]time.Time, []string) {
+	keys := []string{}
+	for key, values := range data.Days {
+		if value, ok := values[metric]; ok && value != skipped {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	dates := make([]time.Time, 0, len(keys))
+	values := make([]string, 0, len(keys))
+	for _, key := range keys {
+		date, err := time.ParseInLocation(dayFormat, key, time.Local)
+		if err != nil {
+			continue
+		}
+		dates = append(dates, date)
+		values = append(values, data.Days[key][metric])
+	}
+	return dates, values
+}
+
+// the days on which the metric with the given key was skipped
+func (data EntryData) skippedDays(metric string) map[string]bool {
+	days := map[string]bool{}
+	for key, values := range data.Days {
+		if values[metric] == skipped {
+			days[key] = true
+		}
+	}
+	return days
+}
+
+// values of all metrics that have an entry on day
+func (data EntryData) day(t time.Time) map[string]string {
+	values := map[string]string{}
+	for metric, value := range data.Days[dayKey(t)] {
+		values[metric] = value
+	}
+	return values
+}
+
+// sets the values for day, replacing whatever was tracked for those metrics
+// that day. An empty value removes the metric's value.
+func (data *EntryData) setDay(t time.Time, values map[string]string) {
+	if data.Days == nil {
+		data.Days = map[string]map[string]string{}
+	}
+	key := dayKey(t)
+	if data.Days[key] == nil {
+		data.Days[key] = map[string]string{}
+	}
+	for metric, value := range values {
+		if value == "" {
+			delete(data.Days[key], metric)
+		} else {
+			data.Days[key][metric] = value
+		}
+	}
+	if len(data.Days[key]) == 0 {
+		delete(data.Days, key)
+	}
+}
+
+// the note written on day, if any
+func (data EntryData) note(t time.Time) string {
+	return data.Notes[dayKey(t)]
+}
+
+// replaces the note of day, an empty note removes it
+func (data *EntryData) setNote(t time.Time, note string) {
+	if data.Notes == nil {
+		data.Notes = map[string]string{}
+	}
+	if strings.TrimSpace(note) == "" {
+		delete(data.Notes, dayKey(t))
+		return
+	}
+	data.Notes[dayKey(t)] = note
+}
+
+// only the days from from to to, both included
+func (data EntryData) between(from time.Time, to time.Time) EntryData {
+	first := dayKey(from)
+	last := dayKey(to)
+	filtered := EntryData{Version: data.Version, Metrics: data.Metrics, Days: map[string]map[string]string{}, Notes: map[string]string{}}
+	for key, values := range data.Days {
+		if key >= first && key <= last {
+			filtered.Days[key] = values
+		}
+	}
+	for key, note := range data.Notes {
+		if key >= first && key <= last {
+			filtered.Notes[key] = note
+		}
+	}
+	return filtered
 }
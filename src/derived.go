@@ -0,0 +1,405 @@
+package src
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// #######################
+// ### DERIVED METRICS ###
+// #######################
+
+// Derived metrics have an expr instead of being entered, e.g.
+// "wake(today) - bed(yesterday)" or "pomos * 25". Metrics are referenced by
+// their alias or by their name in lower case with underscores for spaces.
+
+// a parsed expr, evaluated once per day
+type expr interface {
+	eval(lookup func(ref refExpr) (float64, bool)) (float64, bool)
+}
+
+type numberExpr float64
+
+// another metric's value, offset days away from the day being computed
+type refExpr struct {
+	name   string
+	offset int
+}
+
+type binaryExpr struct {
+	op    rune
+	left  expr
+	right expr
+}
+
+type negExpr struct {
+	operand expr
+}
+
+func (e numberExpr) eval(lookup func(ref refExpr) (float64, bool)) (float64, bool) {
+	return float64(e), true
+}
+
+func (e refExpr) eval(lookup func(ref refExpr) (float64, bool)) (float64, bool) {
+	return lookup(e)
+}
+
+func (e binaryExpr) eval(lookup func(ref refExpr) (float64, bool)) (float64, bool) {
+	left, ok := e.left.eval(lookup)
+	if !ok {
+		return 0, false
+	}
+	right, ok := e.right.eval(lookup)
+	if !ok {
+		return 0, false
+	}
+	switch e.op {
+	case '+':
+		return left + right, true
+	case '-':
+		return left - right, true
+	case '*':
+		return left * right, true
+	default:
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	}
+}
+
+func (e negExpr) eval(lookup func(ref refExpr) (float64, bool)) (float64, bool) {
+	value, ok := e.operand.eval(lookup)
+	return -value, ok
+}
+
+// whether the metric is computed instead of entered
+func (metric Metric) derived() bool {
+	return metric.Expr != ""
+}
+
+// the names an expr can reference the metric by
+func (metric Metric) refNames() []string {
+	names := []string{}
+	if metric.Alias != "" {
+		names = append(names, metric.Alias)
+	}
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '_'
+	}, metric.Name)
+	return append(names, name)
+}
+
+// all metrics references are looked up in
+func metricsByRef(metrics []Metric) map[string]Metric {
+	byRef := map[string]Metric{}
+	for _, metric := range metrics {
+		for _, name := range metric.refNames() {
+			byRef[name] = metric
+		}
+	}
+	return byRef
+}
+
+// makes sure every derived metric's expr parses and only references
+// metrics that are entered, so there are no cycles to worry about. Times
+// from other days have to say when their day starts.
+func checkDerived(metrics []Metric) error {
+	byRef := metricsByRef(metrics)
+	for _, metric := range metrics {
+		if !metric.derived() {
+			continue
+		}
+		e, err := parseExpr(metric.Expr)
+		if err != nil {
+			return fmt.Errorf("metric %v: %v", metric.Name, err)
+		}
+		for _, ref := range exprRefs(e) {
+			other, ok := byRef[ref.name]
+			if !ok {
+				return fmt.Errorf("metric %v: there is no metric called %v", metric.Name, ref.name)
+			}
+			if other.derived() {
+				return fmt.Errorf("metric %v: %v is derived itself", metric.Name, ref.name)
+			}
+			// without a wrap there is no telling whether 00:30 was the night
+			// before or after, bed(yesterday) would be off by a day
+			if rule, err := other.rule(); err == nil && ref.offset != 0 && other.Wrap == "" {
+				if _, ok := rule.(timeRule); ok {
+					return fmt.Errorf("metric %v: %v from another day needs a wrap, e.g. wrap = \"18:00\"", metric.Name, ref.name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func exprRefs(e expr) []refExpr {
+	switch e := e.(type) {
+	case refExpr:
+		return []refExpr{e}
+	case binaryExpr:
+		return append(exprRefs(e.left), exprRefs(e.right)...)
+	case negExpr:
+		return exprRefs(e.operand)
+	}
+	return nil
+}
+
+// how many days away from the day being computed exprs look at most
+func exprReach(metrics []Metric) int {
+	reach := 0
+	for _, metric := range metrics {
+		if !metric.derived() {
+			continue
+		}
+		e, err := parseExpr(metric.Expr)
+		if err != nil {
+			continue
+		}
+		for _, ref := range exprRefs(e) {
+			reach = max(reach, max(ref.offset, -ref.offset))
+		}
+	}
+	return reach
+}
+
+// fills in the values of derived metrics for every day their expr can be
+// computed on. They are never stored, just worked out again on every load.
+func deriveMetrics(data EntryData) EntryData {
+	byRef := metricsByRef(data.Metrics)
+	for _, metric := range data.Metrics {
+		if !metric.derived() {
+			continue
+		}
+		e, err := parseExpr(metric.Expr)
+		if err != nil {
+			continue
+		}
+		rule, err := metric.rule()
+		if err != nil {
+			continue
+		}
+		keys := []string{}
+		for key, values := range data.Days {
+			delete(values, metric.key())
+			keys = append(keys, key)
+		}
+		for _, key := range keys {
+			day, err := time.ParseInLocation(dayFormat, key, time.Local)
+			if err != nil {
+				continue
+			}
+			value, ok := e.eval(func(ref refExpr) (float64, bool) {
+				return refValue(data, byRef[ref.name], day.AddDate(0, 0, ref.offset), ref.offset)
+			})
+			if !ok {
+				continue
+			}
+			formatted := rule.Format(value)
+			if normalizer, ok := rule.(Normalizer); ok {
+				formatted, err = normalizer.Normalize(formatted)
+			}
+			if _, parseErr := rule.Parse(formatted); err != nil || parseErr != nil {
+				// e.g. a negative duration
+				continue
+			}
+			data.Days[key][metric.key()] = formatted
+		}
+	}
+	return data
+}
+
+// the value of metric on day as a number. Times of day from earlier days
+// are moved back by a day each, so bed(yesterday) comes before wake(today).
+func refValue(data EntryData, metric Metric, day time.Time, offset int) (float64, bool) {
+	value := data.Days[dayKey(day)][metric.key()]
+	if value == "" || value == skipped {
+		return 0, false
+	}
+	rule, err := metric.rule()
+	if err != nil {
+		return 0, false
+	}
+	parsed, err := rule.Parse(value)
+	if err != nil {
+		return 0, false
+	}
+	if _, ok := rule.(timeRule); ok {
+		parsed += float64(offset * minutesPerDay)
+	}
+	return parsed, true
+}
+
+// ## EXPR PARSING ##
+
+type exprParser struct {
+	tokens []string
+	pos    int
+}
+
+func parseExpr(source string) (expr, error) {
+	tokens, err := tokenizeExpr(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("expr %q: unexpected %q", source, p.tokens[p.pos])
+	}
+	return e, nil
+}
+
+func tokenizeExpr(source string) ([]string, error) {
+	tokens := []string{}
+	runes := []rune(source)
+	for idx := 0; idx < len(runes); {
+		r := runes[idx]
+		switch {
+		case unicode.IsSpace(r):
+			idx++
+		case strings.ContainsRune("+-*/()", r):
+			tokens = append(tokens, string(r))
+			idx++
+		case unicode.IsDigit(r) || r == '.':
+			start := idx
+			for idx < len(runes) && (unicode.IsDigit(runes[idx]) || runes[idx] == '.') {
+				idx++
+			}
+			tokens = append(tokens, string(runes[start:idx]))
+		case unicode.IsLetter(r) || r == '_':
+			start := idx
+			for idx < len(runes) && (unicode.IsLetter(runes[idx]) || unicode.IsDigit(runes[idx]) || runes[idx] == '_') {
+				idx++
+			}
+			tokens = append(tokens, string(runes[start:idx]))
+		default:
+			return nil, fmt.Errorf("expr %q: unexpected %q", source, r)
+		}
+	}
+	return tokens, nil
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+// sum := product (("+" | "-") product)*
+func (p *exprParser) sum() (expr, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "+" || p.peek() == "-" {
+		op := rune(p.next()[0])
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// product := factor (("*" | "/") factor)*
+func (p *exprParser) product() (expr, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "*" || p.peek() == "/" {
+		op := rune(p.next()[0])
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+// factor := number | "-" factor | "(" sum ")" | name ["(" day ")"]
+func (p *exprParser) factor() (expr, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("expr ends too early")
+	case token == "-":
+		operand, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return negExpr{operand: operand}, nil
+	case token == "(":
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("expr is missing a closing parenthesis")
+		}
+		return e, nil
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		value, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", token)
+		}
+		return numberExpr(value), nil
+	case unicode.IsLetter(rune(token[0])) || token[0] == '_':
+		ref := refExpr{name: token}
+		if p.peek() == "(" {
+			p.next()
+			offset, err := p.day()
+			if err != nil {
+				return nil, err
+			}
+			if p.next() != ")" {
+				return nil, fmt.Errorf("%v(...) is missing a closing parenthesis", token)
+			}
+			ref.offset = offset
+		}
+		return ref, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", token)
+}
+
+// day := "today" | "yesterday" | ["-"] number
+func (p *exprParser) day() (int, error) {
+	token := p.next()
+	switch token {
+	case "today":
+		return 0, nil
+	case "yesterday":
+		return -1, nil
+	case "-":
+		days, err := strconv.Atoi(p.next())
+		if err != nil {
+			return 0, fmt.Errorf("days have to be today, yesterday or a whole number like -2")
+		}
+		return -days, nil
+	}
+	days, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("days have to be today, yesterday or a whole number like -2")
+	}
+	return days, nil
+}
@@ -0,0 +1,50 @@
+package src
+
+import "testing"
+
+func TestSleepFromBedAndWake(t *testing.T) {
+	tests := []struct {
+		name  string
+		bed   string // the day before
+		wake  string
+		slept string // in minutes, like durations are stored
+	}{
+		{name: "to bed before midnight", bed: "23:30", wake: "07:00", slept: "450"},
+		{name: "to bed after midnight", bed: "00:30", wake: "07:00", slept: "390"},
+		{name: "to bed late", bed: "02:00", wake: "08:15", slept: "375"},
+	}
+	metrics := []Metric{
+		{ID: 1, Name: "Got up", Alias: "wake", Rule: "time"},
+		{ID: 2, Name: "Went to bed", Alias: "bed", Rule: "time", Wrap: "18:00"},
+		{ID: 3, Name: "Slept", Rule: "duration", Expr: "wake(today) - bed(yesterday)"},
+	}
+	if err := checkDerived(metrics); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := EntryData{Metrics: metrics, Days: map[string]map[string]string{
+				"2023-01-02": {metrics[1].key(): tt.bed},
+				"2023-01-03": {metrics[0].key(): tt.wake},
+			}}
+			if slept := deriveMetrics(data).Days["2023-01-03"][metrics[2].key()]; slept != tt.slept {
+				t.Errorf("slept %v, want %v", slept, tt.slept)
+			}
+		})
+	}
+}
+
+func TestTimeFromAnotherDayNeedsWrap(t *testing.T) {
+	metrics := []Metric{
+		{ID: 1, Name: "Got up", Alias: "wake", Rule: "time"},
+		{ID: 2, Name: "Went to bed", Alias: "bed", Rule: "time"},
+		{ID: 3, Name: "Slept", Rule: "duration", Expr: "wake(today) - bed(yesterday)"},
+	}
+	if err := checkDerived(metrics); err == nil {
+		t.Error("bed(yesterday) without a wrap was taken")
+	}
+	metrics[2].Expr = "wake - bed"
+	if err := checkDerived(metrics); err != nil {
+		t.Errorf("times from the same day were rejected: %v", err)
+	}
+}
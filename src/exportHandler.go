@@ -0,0 +1,87 @@
+package src
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ############
+// ## EXPORT ##
+// ############
+
+// one exported day, values are keyed by metric name
+type exportedDay struct {
+	Date   string            `json:"date"`
+	Values map[string]string `json:"values"`
+	Note   string            `json:"note,omitempty"`
+}
+
+// Export writes every tracked day with its values and note to w,
+// as "csv" (one column per metric) or "json".
+func Export(store Store, format string, w io.Writer) error {
+	data, _, err := syncMetrics(store)
+	if err != nil {
+		return err
+	}
+	days := exportDays(deriveMetrics(data))
+
+	switch format {
+	case "", "csv":
+		out := csv.NewWriter(w)
+		header := []string{"date"}
+		for _, metric := range data.Metrics {
+			header = append(header, metric.Name)
+		}
+		header = append(header, "note")
+		if err := out.Write(header); err != nil {
+			return err
+		}
+		for _, day := range days {
+			record := []string{day.Date}
+			for _, metric := range data.Metrics {
+				record = append(record, day.Values[metric.Name])
+			}
+			record = append(record, day.Note)
+			if err := out.Write(record); err != nil {
+				return err
+			}
+		}
+		out.Flush()
+		return out.Error()
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(days)
+	default:
+		return fmt.Errorf("unknown export format %q, use csv or json", format)
+	}
+}
+
+// days with values or a note, oldest first
+func exportDays(data EntryData) []exportedDay {
+	keys := []string{}
+	for key := range data.Days {
+		keys = append(keys, key)
+	}
+	for key := range data.Notes {
+		if _, ok := data.Days[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	days := []exportedDay{}
+	for _, key := range keys {
+		day := exportedDay{Date: key, Values: map[string]string{}, Note: data.Notes[key]}
+		for _, metric := range data.Metrics {
+			if value, ok := data.Days[key][metric.key()]; ok {
+				day.Values[metric.Name] = value
+			}
+		}
+		days = append(days, day)
+	}
+	return days
+}
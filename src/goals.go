@@ -0,0 +1,114 @@
+package src
+
+import (
+	"fmt"
+	"strings"
+)
+
+// a target like ">= 8" or "<= 06:30" that a metric's values are held against
+type goal struct {
+	op     string
+	target float64
+	spec   string
+}
+
+// longest operators first so ">=" isn't read as ">"
+var goalOps = []string{">=", "<=", "==", ">", "<", "="}
+
+// reads a goal from the config, the target is written like any other value
+// of the metric's rule
+func parseGoal(spec string, rule Rule) (goal, error) {
+	spec = strings.TrimSpace(spec)
+	for _, op := range goalOps {
+		if !strings.HasPrefix(spec, op) {
+			continue
+		}
+		target, err := rule.Parse(strings.TrimSpace(spec[len(op):]))
+		if err != nil {
+			return goal{}, fmt.Errorf("goal %q: %v", spec, err)
+		}
+		return goal{op: op, target: target, spec: spec}, nil
+	}
+	return goal{}, fmt.Errorf("goal %q has to start with one of %v", spec, strings.Join(goalOps, " "))
+}
+
+func (g goal) met(value float64) bool {
+	switch g.op {
+	case ">=":
+		return value >= g.target
+	case "<=":
+		return value <= g.target
+	case ">":
+		return value > g.target
+	case "<":
+		return value < g.target
+	default:
+		return value == g.target
+	}
+}
+
+// the goal set for the metric, ok is false if there is none
+func (metric Metric) goal() (g goal, ok bool, err error) {
+	if metric.Goal == "" {
+		return goal{}, false, nil
+	}
+	rule, err := metric.rule()
+	if err != nil {
+		return goal{}, false, err
+	}
+	g, err = parseGoal(metric.Goal, rule)
+	if err != nil {
+		return goal{}, false, fmt.Errorf("metric %v: %v", metric.Name, err)
+	}
+	return g, true, nil
+}
+
+// whether a tracked value meets the metric's goal, values that can't be
+// parsed never do
+func (metric Metric) meetsGoal(g goal, value string) bool {
+	rule, err := metric.rule()
+	if err != nil {
+		return false
+	}
+	parsed, err := rule.Parse(value)
+	if err != nil {
+		return false
+	}
+	return g.met(parsed)
+}
+
+// ## LOWER IS BETTER ##
+
+// checks direction and kind of a metric from the config
+func (metric Metric) checkDirection() error {
+	if metric.Direction != "" && metric.Direction != "higher" && metric.Direction != "lower" {
+		return fmt.Errorf("metric %v: direction has to be \"higher\" or \"lower\"", metric.Name)
+	}
+	if metric.Kind != "" && metric.Kind != "avoid" {
+		return fmt.Errorf("metric %v: the only kind there is is \"avoid\"", metric.Name)
+	}
+	return nil
+}
+
+// whether less of the metric is better, avoided habits always are
+func (metric Metric) lowerIsBetter() bool {
+	return metric.Direction == "lower" || metric.avoided()
+}
+
+func (metric Metric) avoided() bool {
+	return metric.Kind == "avoid"
+}
+
+// whether a day of an avoided habit was clean: the goal was met if there is
+// one, none of it happened otherwise
+func (metric Metric) clean(value string) bool {
+	if g, ok, _ := metric.goal(); ok {
+		return metric.meetsGoal(g, value)
+	}
+	rule, err := metric.rule()
+	if err != nil {
+		return false
+	}
+	parsed, err := rule.Parse(value)
+	return err == nil && parsed <= 0
+}
@@ -0,0 +1,227 @@
+package src
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// #######################
+// ### DATA MIGRATIONS ###
+// #######################
+
+// Every change to the layout of data.json gets a step here that upgrades raw
+// file contents from one version to the next. Steps only ever see the layout
+// of their own version, so they keep working however EntryData changes later.
+var migrations = map[int]func([]byte) ([]byte, error){
+	1: migrateLegacy,
+	2: migrateTypedMetrics,
+	3: migrateMetricIDs,
+	4: addNotes,
+}
+
+// version 1: parallel date and value slices per metric, no version field yet
+type legacyMetricData struct {
+	Name   string
+	Date   []time.Time
+	Value  []string
+	Color1 string
+	Color2 string
+}
+
+type legacyEntryData struct {
+	Metrics map[int][]string
+	Data    []legacyMetricData
+}
+
+// version 2: days keyed by date, metrics still positional [name, rule, color1, color2]
+type entryDataV2 struct {
+	Version int                          `json:"version"`
+	Metrics map[int][]string             `json:"metrics"`
+	Days    map[string]map[string]string `json:"days"`
+}
+
+// version 3: metrics as objects, days keyed by metric name
+type metricV3 struct {
+	Name     string `json:"name"`
+	Rule     string `json:"rule"`
+	Color1   string `json:"color1"`
+	Color2   string `json:"color2"`
+	Archived bool   `json:"archived,omitempty"`
+}
+
+type entryDataV3 struct {
+	Version int                          `json:"version"`
+	Metrics []metricV3                   `json:"metrics"`
+	Days    map[string]map[string]string `json:"days"`
+}
+
+// version 4: metrics get IDs and days are keyed by them
+type metricV4 struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Rule     string `json:"rule"`
+	Color1   string `json:"color1"`
+	Color2   string `json:"color2"`
+	Archived bool   `json:"archived,omitempty"`
+}
+
+type entryDataV4 struct {
+	Version int                          `json:"version"`
+	Metrics []metricV4                   `json:"metrics"`
+	Days    map[string]map[string]string `json:"days"`
+}
+
+// brings raw data.json contents up to dataVersion, backing up the file
+// before touching it. Reports whether anything had to be changed.
+func migrateData(raw []byte) ([]byte, bool, error) {
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return raw, false, err
+	}
+	version := header.Version
+	if version == 0 {
+		// files from before versioning
+		version = 1
+	}
+	if version == dataVersion {
+		return raw, false, nil
+	}
+	if version > dataVersion {
+		return raw, false, fmt.Errorf("data file has version %d, this nikki only knows up to %d", version, dataVersion)
+	}
+
+	// kept for good, unlike the rotating backups
+	backup := fmt.Sprintf("%v.v%d.bak", dataPath, version)
+	if err := writeFileAtomic(backup, raw, 0644); err != nil {
+		return raw, false, fmt.Errorf("couldn't back up data before migrating: %v", err)
+	}
+	for ; version < dataVersion; version++ {
+		step, ok := migrations[version]
+		if !ok {
+			return raw, false, fmt.Errorf("don't know how to migrate data from version %d", version)
+		}
+		migrated, err := step(raw)
+		if err != nil {
+			return raw, false, fmt.Errorf("migrating data from version %d: %v", version, err)
+		}
+		raw = migrated
+	}
+	return raw, true, nil
+}
+
+// 1 -> 2: turns the parallel slices into days keyed by date.
+// If a day was logged more than once the last value wins.
+func migrateLegacy(raw []byte) ([]byte, error) {
+	var legacy legacyEntryData
+	if err := json.Unmarshal(raw, &legacy); err != nil {
+		return raw, err
+	}
+	data := entryDataV2{Version: 2, Metrics: legacy.Metrics, Days: map[string]map[string]string{}}
+	if data.Metrics == nil {
+		data.Metrics = map[int][]string{}
+	}
+	for _, metric := range legacy.Data {
+		if len(metric.Date) != len(metric.Value) {
+			return raw, fmt.Errorf("metric %v has %d dates but %d values", metric.Name, len(metric.Date), len(metric.Value))
+		}
+		for idx, date := range metric.Date {
+			key := dayKey(date)
+			if data.Days[key] == nil {
+				data.Days[key] = map[string]string{}
+			}
+			data.Days[key][metric.Name] = metric.Value[idx]
+		}
+	}
+	return json.Marshal(data)
+}
+
+// 2 -> 3: positional metric slices become Metric objects, in index order
+func migrateTypedMetrics(raw []byte) ([]byte, error) {
+	var old entryDataV2
+	if err := json.Unmarshal(raw, &old); err != nil {
+		return raw, err
+	}
+	indices := []int{}
+	for idx := range old.Metrics {
+		indices = append(indices, idx)
+	}
+	sort.Ints(indices)
+
+	data := entryDataV3{Version: 3, Metrics: []metricV3{}, Days: old.Days}
+	for _, idx := range indices {
+		fields := old.Metrics[idx]
+		// pad in case a definition was cut short
+		for len(fields) < 4 {
+			fields = append(fields, "")
+		}
+		data.Metrics = append(data.Metrics, metricV3{
+			Name:   fields[0],
+			Rule:   fields[1],
+			Color1: fields[2],
+			Color2: fields[3],
+		})
+	}
+	return json.Marshal(data)
+}
+
+// 3 -> 4: numbers the metrics in their current order and rekeys the days by
+// those numbers. Values without a metric definition get an archived one.
+func migrateMetricIDs(raw []byte) ([]byte, error) {
+	var old entryDataV3
+	if err := json.Unmarshal(raw, &old); err != nil {
+		return raw, err
+	}
+	data := entryDataV4{Version: 4, Metrics: []metricV4{}, Days: map[string]map[string]string{}}
+	ids := map[string]string{}
+	for idx, metric := range old.Metrics {
+		data.Metrics = append(data.Metrics, metricV4{
+			ID:       idx + 1,
+			Name:     metric.Name,
+			Rule:     metric.Rule,
+			Color1:   metric.Color1,
+			Color2:   metric.Color2,
+			Archived: metric.Archived,
+		})
+		ids[metric.Name] = strconv.Itoa(idx + 1)
+	}
+	days := []string{}
+	for day := range old.Days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	for _, day := range days {
+		data.Days[day] = map[string]string{}
+		names := []string{}
+		for name := range old.Days[day] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value := old.Days[day][name]
+			id, ok := ids[name]
+			if !ok {
+				id = strconv.Itoa(len(data.Metrics) + 1)
+				data.Metrics = append(data.Metrics, metricV4{ID: len(data.Metrics) + 1, Name: name, Archived: true})
+				ids[name] = id
+			}
+			data.Days[day][id] = value
+		}
+	}
+	return json.Marshal(data)
+}
+
+// 4 -> 5: days can have notes now, which older files simply don't have
+func addNotes(raw []byte) ([]byte, error) {
+	var data map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return raw, err
+	}
+	data["version"] = json.RawMessage("5")
+	data["notes"] = json.RawMessage("{}")
+	return json.Marshal(data)
+}
@@ -3,6 +3,7 @@ package src
 import (
 	"fmt"
 	"github.com/charmbracelet/bubbles/cursor"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -10,6 +11,7 @@ import (
 	"github.com/muesli/reflow/indent"
 	"golang.org/x/term"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -28,60 +30,95 @@ type model struct {
 	data          EntryData // data lül
 	quitting      bool
 	inputs        []textinput.Model
+	dateInput     textinput.Model // which day the entry form is for
+	entryDate     time.Time
+	noteInput     textarea.Model
+	calendarDay   time.Time // day selected in the calendar
+	showDay       bool      // whether the day detail panel is open
+	showStats     bool      // whether the stats panel is open
+	statsRange    int       // which of statsRanges the panel covers
+	corrRow       int       // pair picked in the correlation matrix
+	corrCol       int
+	corrLag       int  // days the row metric is taken before the column
+	showScatter   bool // whether the picked pair is plotted
 	cursorMode    cursor.Mode
-	focusIndex    int
+	focusIndex    int // 0 is the date, then the inputs, the note and submit
 	wrongInput    bool
 	wrongIndex    int
+	wrongReason   string // what the rule had to say about the wrong input
+	wrongDate     bool
+	saveError     string // why the last submit couldn't be saved
 	generalConfig General
+	store         Store
 }
 
-func InitialModel() model {
-	data, metrics, updatedMetrics, newMetricNames := checkConfig()
-
-	// Check if config has changed
-	configChanged := checkForConfigChanges(newMetricNames, metrics)
-
-	if configChanged {
-		data = checkMetrics(metrics, newMetricNames, updatedMetrics, data)
+func InitialModel(store Store) (model, error) {
+	data, metrics, err := syncMetrics(store)
+	if err != nil {
+		return model{}, err
+	}
+	data = deriveMetrics(data)
+	// derived metrics come last and can't be entered
+	entered := 0
+	for _, metric := range data.Metrics {
+		if !metric.Archived && !metric.derived() {
+			entered++
+		}
 	}
 
-	storeJSON(data)
-
 	cfg := ReadConfig()
 
 	m := model{
 		metrics:       metrics,
 		data:          data,
-		choices:       []string{"view calendar", "add entry"},
+		choices:       []string{"view calendar", "add entry", "correlations"},
 		chosen:        false,
 		quitting:      false,
-		inputs:        make([]textinput.Model, len(metrics)),
+		inputs:        make([]textinput.Model, entered),
 		wrongInput:    false,
+		calendarDay:   dayStart(time.Now()),
 		generalConfig: cfg.General,
+		store:         store,
 	}
 
 	var (
 		focusedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(m.generalConfig.ActiveButtonColor))
 		cursorStyle  = focusedStyle.Copy()
 	)
+	// labels line up the inputs, placeholders vanish once a day is prefilled
+	labelWidth := len("Day")
+	for _, metric := range m.metrics {
+		labelWidth = max(labelWidth, lipgloss.Width(metric))
+	}
 	var t textinput.Model
-	for i := range m.metrics {
+	for i := range m.inputs {
 		t = textinput.New()
 		t.CursorStyle = cursorStyle
-		switch i {
-		case 0:
-			t.Placeholder = m.metrics[i]
-			t.Focus()
-			t.PromptStyle = focusedStyle
-			t.TextStyle = focusedStyle
-		default:
-			t.Placeholder = m.metrics[i]
-
+		t.Prompt = fmt.Sprintf("%-*s > ", labelWidth, m.metrics[i])
+		t.Placeholder = m.metrics[i]
+		if rule, err := m.data.Metrics[i].rule(); err == nil {
+			t.Placeholder = rule.Hint()
 		}
 		m.inputs[i] = t
 	}
+	m.dateInput = textinput.New()
+	m.dateInput.CursorStyle = cursorStyle
+	m.dateInput.Prompt = fmt.Sprintf("%-*s > ", labelWidth, "Day")
+	m.dateInput.Placeholder = dayFormat
+	m.dateInput.CharLimit = len(dayFormat)
+	m.dateInput.Focus()
+	m.dateInput.PromptStyle = focusedStyle
+	m.dateInput.TextStyle = focusedStyle
+	m.noteInput = textarea.New()
+	m.noteInput.Placeholder = "Anything else about the day?"
+	m.noteInput.ShowLineNumbers = false
+	m.noteInput.CharLimit = 0
+	m.noteInput.SetWidth(60)
+	m.noteInput.SetHeight(4)
+	m.noteInput.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	m.loadEntryDate(time.Now())
 
-	return m
+	return m, nil
 }
 
 func (m model) Init() tea.Cmd {
@@ -96,6 +133,11 @@ func (m *model) updateInputs(msg tea.Msg) tea.Cmd {
 	for i := range m.inputs {
 		m.inputs[i], cmds[i] = m.inputs[i].Update(msg)
 	}
+	var cmd tea.Cmd
+	m.dateInput, cmd = m.dateInput.Update(msg)
+	cmds = append(cmds, cmd)
+	m.noteInput, cmd = m.noteInput.Update(msg)
+	cmds = append(cmds, cmd)
 
 	return tea.Batch(cmds...)
 }
@@ -104,7 +146,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	// Make sure these keys always quit
 	if msg, ok := msg.(tea.KeyMsg); ok {
 		k := msg.String()
-		if k == "q" || k == "esc" || k == "ctrl+c" {
+		if (k == "q" && !m.typingNote()) || k == "esc" || k == "ctrl+c" {
 			m.quitting = true
 			return m, tea.Quit
 		}
@@ -122,83 +164,60 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 // ## METRIC MAINTENANCE & UPDATES ##
 // ##################################
 
-func checkMetrics(metrics []string, newMetricNames []string, updatedMetrics map[int][]string, data EntryData) EntryData {
-	// Check if elements were deleted, delete corresponding entries if so
-	metricDeleted, deletedMetrics := checkForDeletedMetrics(metrics, newMetricNames)
-	if metricDeleted {
-		data.Data, metrics = deleteMetrics(data.Data, deletedMetrics)
+// loads the stored data and brings its metrics in line with the config,
+// returns the data and the names of the metrics that are not archived
+func syncMetrics(store Store) (EntryData, []string, error) {
+	stored, err := store.Load()
+	if err != nil {
+		return stored, nil, err
 	}
-	// Check if elements were added, add new entries if so
-	metricAdded, addedMetrics := checkForAddedMetrics(metrics, newMetricNames)
-	if metricAdded {
-		data.Data = addMetrics(addedMetrics, data.Data)
+	data, metrics, updatedMetrics, newMetricNames, err := checkConfig(stored)
+	if err != nil {
+		return data, nil, err
 	}
-	// Check if elements were rearranged, properly order them
-	// use correct metrics
-	data.Metrics = updatedMetrics
-	data = updateMetrics(data, metrics, newMetricNames)
 
-	return data
-}
+	// Check if config has changed, colors and rules included
+	configChanged := checkForConfigChanges(newMetricNames, metrics) || !reflect.DeepEqual(storedMetrics(updatedMetrics), data.Metrics)
 
-func addMetrics(addedMetrics []string, data []MetricData) []MetricData {
-	newData := data
-	for dontCare := 0; dontCare < len(addedMetrics); dontCare++ {
-		newData = append(newData, MetricData{})
-	}
-	if len(addedMetrics) > 0 {
-		counter := 0
-		for idx, _ := range newData {
-			if newData[idx].Name == "" {
-				newData[idx].Name = addedMetrics[counter]
-				counter += 1
-			}
+	// options that only live in the config come along either way
+	data = checkMetrics(updatedMetrics, data)
+	metrics = newMetricNames
+	if configChanged {
+		// the entries stay as they are, only the metric layout changed
+		if err := store.PutMetrics(storedMetrics(data.Metrics)); err != nil {
+			return data, nil, err
 		}
 	}
-	return newData
+	return data, metrics, nil
 }
 
-func updateMetrics(data EntryData, metrics []string, newMetricNames []string) EntryData {
-	metrics = newMetricNames
-	newData := make([]MetricData, len(metrics))
-	for idx, ele := range data.Data {
-		foundCorrectIdx := false
-		for sIdx := 0; sIdx < len(metrics); sIdx++ {
-			if ele.Name == metrics[sIdx] {
-				newData[sIdx] = ele
-				newData[sIdx].Name = metrics[sIdx]
-				foundCorrectIdx = true
-				break
-				// fmt.Printf("newData2: %v\n", newData)
-			}
-		}
-		if foundCorrectIdx == false {
-			newData[idx] = MetricData{
-				Name:   metrics[idx],
-				Date:   []time.Time{},
-				Value:  []string{},
-				Color1: data.Metrics[idx][2],
-				Color2: data.Metrics[idx][3],
-			}
-		}
-		data.Data = newData
+func storedMetrics(metrics []Metric) []Metric {
+	stored := []Metric{}
+	for _, metric := range metrics {
+		stored = append(stored, metric.stored())
 	}
-	return data
+	return stored
 }
 
-func deleteMetrics(data []MetricData, deletedMetrics []string) ([]MetricData, []string) {
-	var newData []MetricData
-	newMetrics := []string{}
+func checkMetrics(updatedMetrics []Metric, data EntryData) EntryData {
+	// removed metrics were archived by checkConfig, added and rearranged ones
+	// only need the new layout, days are keyed by metric ID so nothing has to move around
+	data.Metrics = updatedMetrics
 
-	for _, currData := range data {
-		// if metric is not in deletedMetrics, add data to new data
-		if !contains(deletedMetrics, currData.Name) {
-			newData = append(newData, currData)
-			newMetrics = append(newMetrics, currData.Name)
+	return data
+}
+
+// drops every value of the given metrics, there is no coming back from this
+func deleteMetrics(data EntryData, deletedMetrics []string) EntryData {
+	for key, values := range data.Days {
+		for _, metric := range deletedMetrics {
+			delete(values, metric)
+		}
+		if len(values) == 0 {
+			delete(data.Days, key)
 		}
 	}
-	// fmt.Printf("newData: %v\n", newData)
-	return newData, newMetrics
+	return data
 }
 
 // ##########################
@@ -270,6 +289,8 @@ func chosenView(m model) string {
 		s = calendarView(m)
 	case 1:
 		s = newEntryView(m)
+	case 2:
+		s = correlationView(m)
 	}
 	return s
 }
@@ -312,21 +333,93 @@ func calendarView(m model) string {
 	row = lipgloss.JoinHorizontal(lipgloss.Bottom, row, gap)
 	s += row
 	s += "\n\n"
-	if len(m.data.Data[m.cursor2].Value) >= 1 {
-		zeGrid := createGrid(m.data, "year", time.Now(), m.cursor2)
-		s += prerenderGrid(zeGrid)
-
-		// min, avg and max value
-		mmin, mmax, mavg := getMinMaxAvg(m.data, m.cursor2)
-		mmin = "Minumum:  " + mmin + " || "
-		mavg = "Average:  " + mavg + " || "
-		mmax = "Maximum:  " + mmax
-		minMaxAvgString := lipgloss.JoinHorizontal(lipgloss.Center, mmin, mavg, mmax)
+	if _, values := m.data.series(m.data.Metrics[m.cursor2].key()); len(values) >= 1 {
+		zeGrid := createGrid(m.data, "year", m.calendarDay, m.cursor2)
+		row, col := gridPosition(m.calendarDay)
+		s += prerenderGrid(zeGrid, row, col, m.generalConfig.ActiveButtonColor)
+
+		// min, avg and max value, or how often a habit got done
+		var minMaxAvgString string
+		if m.data.Metrics[m.cursor2].isBool() {
+			done, total := getCompletion(m.data, m.cursor2)
+			unit := "days"
+			if m.data.Metrics[m.cursor2].Schedule != "" {
+				unit = "times due"
+			}
+			minMaxAvgString = fmt.Sprintf("Done:  %d of %d %v || Completion:  %d%%", done, total, unit, done*100/max(total, 1))
+		} else if m.data.Metrics[m.cursor2].isChoice() {
+			choices, frequencies := getFrequencies(m.data, m.cursor2)
+			parts := []string{}
+			for idx, choice := range choices {
+				parts = append(parts, fmt.Sprintf("%v:  %d (%d%%)", choice, frequencies[idx], frequencies[idx]*100/len(values)))
+			}
+			minMaxAvgString = strings.Join(parts, " || ")
+		} else {
+			mmin, mmax, mavg := getMinMaxAvg(m.data, m.cursor2)
+			mmin = "Minumum:  " + mmin + " || "
+			mavg = "Average:  " + mavg + " || "
+			mmax = "Maximum:  " + mmax
+			minMaxAvgString = lipgloss.JoinHorizontal(lipgloss.Center, mmin, mavg, mmax)
+			if m.data.Metrics[m.cursor2].isDuration() {
+				// overall and for this week and the one before
+				week := weekStart(time.Now())
+				thisWeek, err := getWeekTotal(m.store, m.data, m.cursor2, week)
+				lastWeek, lastErr := getWeekTotal(m.store, m.data, m.cursor2, week.AddDate(0, 0, -7))
+				totals := fmt.Sprintf("Total:  %v || This week:  %v || Last week:  %v", getTotal(m.data, m.cursor2), thisWeek, lastWeek)
+				if err == nil {
+					err = lastErr
+				}
+				if err != nil {
+					totals = "Couldn't read the weekly totals: " + err.Error()
+				}
+				minMaxAvgString = lipgloss.JoinVertical(lipgloss.Center, minMaxAvgString, totals)
+			}
+		}
 		question := lipgloss.NewStyle().Width(70).Align(lipgloss.Center).Render(minMaxAvgString)
-		currStreak, LongestStreak := streakChecker(m.data, m.cursor2)
-		cStreak := "Current Streak:  " + strconv.Itoa(currStreak) + " || "
-		lStreak := "Longest Streak:  " + strconv.Itoa(LongestStreak)
+		if g, ok, _ := m.data.Metrics[m.cursor2].goal(); ok {
+			// hit rate for the year on screen
+			year := m.calendarDay.Year()
+			hits, total, err := getGoalHits(m.store, m.data, m.cursor2, g,
+				time.Date(year, 1, 1, 0, 0, 0, 0, time.Local), time.Date(year, 12, 31, 0, 0, 0, 0, time.Local))
+			goalString := fmt.Sprintf("Goal:  %v || Hit in %d:  %d of %d days", g.spec, year, hits, total)
+			if err != nil {
+				goalString = fmt.Sprintf("Goal:  %v || Couldn't read %d: %v", g.spec, year, err)
+			} else if total > 0 {
+				goalString += fmt.Sprintf(" (%d%%)", hits*100/total)
+			}
+			question = lipgloss.JoinVertical(lipgloss.Center, question,
+				lipgloss.NewStyle().Width(70).Align(lipgloss.Center).Render(goalString))
+		}
+		if t, ok, _ := m.data.Metrics[m.cursor2].target(); ok {
+			// progress in the period on screen and streaks of whole periods
+			done, label := getPeriodProgress(m.data, m.cursor2, t, m.calendarDay)
+			current, longest := periodStreaks(m.data, m.cursor2, t)
+			targetString := fmt.Sprintf("Target:  %v || %v:  %d/%d || %vs in a row:  %d || Longest:  %d",
+				t.spec, strings.ToUpper(label[:1])+label[1:], done, t.times, strings.ToUpper(t.period[:1])+t.period[1:], current, longest)
+			question = lipgloss.JoinVertical(lipgloss.Center, question,
+				lipgloss.NewStyle().Width(70).Align(lipgloss.Center).Render(targetString))
+		}
+		current, longest := getStreaks(m.data, m.cursor2, time.Now())
+		cStreak := "Current Streak:  " + strconv.Itoa(current.length) + " || "
+		lStreak := "Longest Streak:  " + strconv.Itoa(longest.length)
+		if m.data.Metrics[m.cursor2].avoided() {
+			clean := len(streakDates(m.data, m.cursor2))
+			cStreak = "Days clean:  " + strconv.Itoa(current.length) + " || "
+			lStreak = "Longest clean run:  " + strconv.Itoa(longest.length) + " || " +
+				fmt.Sprintf("Clean:  %d of %d days", clean, len(values))
+		}
+		// when they went
+		spans := []string{}
+		if current.length > 0 {
+			spans = append(spans, "Current:  "+current.span())
+		}
+		if longest.length > 0 && longest != current {
+			spans = append(spans, "Longest:  "+longest.span())
+		}
 		streakString := lipgloss.JoinHorizontal(lipgloss.Center, cStreak, lStreak)
+		if len(spans) > 0 {
+			streakString = lipgloss.JoinVertical(lipgloss.Center, streakString, strings.Join(spans, " || "))
+		}
 		streak_render := lipgloss.NewStyle().Width(70).Align(lipgloss.Center).Render(streakString)
 		ui := lipgloss.JoinVertical(lipgloss.Center, question, streak_render)
 		dialog := lipgloss.Place(width, 9,
@@ -358,11 +451,104 @@ func calendarView(m model) string {
 		s += dialog
 	}
 
+	if m.showDay {
+		dialog := lipgloss.Place(width, 9,
+			lipgloss.Center, lipgloss.Center,
+			dialogBoxStyle.Render(dayDetailView(m)),
+			lipgloss.WithWhitespaceForeground(subtle),
+		)
+		s += "\n" + dialog
+	}
+	if m.showStats {
+		dialog := lipgloss.Place(width, 9,
+			lipgloss.Center, lipgloss.Center,
+			dialogBoxStyle.Render(statsView(m)),
+			lipgloss.WithWhitespaceForeground(subtle),
+		)
+		s += "\n" + dialog
+	}
+
 	// The footer
-	s += "\n\nPress b to return to the menu.\nPress q to quit."
+	s += "\n\nPress [ and ] to pick a day, { and } to jump a week, d to show it."
+	s += "\nPress s for more stats, r to change what they cover."
+	s += "\nPress b to return to the menu.\nPress q to quit."
 	return s
 }
 
+// everything tracked on the selected day, including its note
+func dayDetailView(m model) string {
+	labelWidth := 0
+	for _, metric := range m.metrics {
+		labelWidth = max(labelWidth, lipgloss.Width(metric))
+	}
+	b := strings.Builder{}
+	b.WriteString(m.calendarDay.Format("Monday, 2 January 2006"))
+	b.WriteString("\n\n")
+	values := m.data.day(m.calendarDay)
+	for idx, name := range m.metrics {
+		value, ok := values[m.data.Metrics[idx].key()]
+		if !ok {
+			value = "no data"
+		} else if value == skipped {
+			value = "skipped"
+		} else {
+			value = m.data.Metrics[idx].display(value)
+		}
+		b.WriteString(fmt.Sprintf("%-*s  %v\n", labelWidth, name, value))
+	}
+	if note := m.data.note(m.calendarDay); note != "" {
+		b.WriteRune('\n')
+		b.WriteString(lipgloss.NewStyle().Width(66).Render(note))
+	} else {
+		b.WriteString("\nNo note for this day.")
+	}
+	return lipgloss.NewStyle().Width(66).Padding(0, 2).Render(b.String())
+}
+
+// the stats panel for the metric on screen over the chosen range
+func statsView(m model) string {
+	stats, err := getStats(m.store, m.data, m.cursor2, m.statsRange, time.Now())
+	b := strings.Builder{}
+	b.WriteString(fmt.Sprintf("%v, %v", m.metrics[m.cursor2], statsRanges[m.statsRange]))
+	b.WriteString("\n\n")
+	if err != nil {
+		b.WriteString("Couldn't read this time from the store: " + err.Error())
+		return lipgloss.NewStyle().Width(66).Padding(0, 2).Render(b.String())
+	}
+	if stats.count == 0 {
+		b.WriteString("Nothing tracked in this time.")
+		return lipgloss.NewStyle().Width(66).Padding(0, 2).Render(b.String())
+	}
+	completion := 0
+	if stats.due > 0 {
+		completion = stats.done * 100 / stats.due
+	}
+	rows := [][2]string{
+		{"Days logged", strconv.Itoa(stats.count)},
+		{"Completion", fmt.Sprintf("%d of %d days (%d%%)", stats.done, stats.due, completion)},
+		{"Median", stats.median},
+		{"Std. deviation", stats.stddev},
+		{"Percentiles", fmt.Sprintf("10th %v  25th %v  75th %v  90th %v",
+			stats.percentiles[0], stats.percentiles[1], stats.percentiles[2], stats.percentiles[3])},
+		{"Best weekday", stats.bestDay},
+		{"Worst weekday", stats.worstDay},
+	}
+	for _, row := range rows {
+		b.WriteString(fmt.Sprintf("%-15s %v\n", row[0], row[1]))
+	}
+	months := []string{}
+	for _, month := range stats.months {
+		label := month.month.Format("Jan")
+		if month.month.Month() == time.January || len(months) == 0 {
+			label = month.month.Format("Jan 2006")
+		}
+		months = append(months, label+"  "+month.average)
+	}
+	b.WriteString("\nBy month\n")
+	b.WriteString(lipgloss.NewStyle().Width(62).Render(strings.Join(months, " || ")))
+	return lipgloss.NewStyle().Width(66).Padding(0, 2).Render(b.String())
+}
+
 func newEntryView(m model) string {
 	var (
 		focusedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color(m.generalConfig.ActiveButtonColor))
@@ -373,21 +559,50 @@ func newEntryView(m model) string {
 	)
 	var b strings.Builder
 
+	b.WriteString(m.dateInput.View())
+	b.WriteString(blurredStyle.Render("  " + m.entryDate.Format("Monday")))
+	if m.focusIndex == 0 {
+		b.WriteString(lipgloss.NewStyle().Faint(true).Render("  ←/→ to change the day, or type one"))
+	}
+	b.WriteString("\n\n")
 	for i := range m.inputs {
-		b.WriteString(m.inputs[i].View())
+		if rule, err := m.data.Metrics[i].rule(); err == nil {
+			if picker, ok := rule.(Picker); ok {
+				b.WriteString(m.inputs[i].PromptStyle.Render(m.inputs[i].Prompt))
+				b.WriteString(m.inputs[i].TextStyle.Render(picker.Render(m.inputs[i].Value())))
+				if m.focusIndex == i+1 {
+					b.WriteString(lipgloss.NewStyle().Faint(true).Render("  " + picker.Hint()))
+				}
+			} else {
+				b.WriteString(m.inputs[i].View())
+			}
+		} else {
+			b.WriteString(m.inputs[i].View())
+		}
 		if i < len(m.inputs)-1 {
 			b.WriteRune('\n')
 		}
 	}
+	b.WriteString("\n\n")
+	b.WriteString(blurredStyle.Render("Note"))
+	b.WriteRune('\n')
+	b.WriteString(m.noteInput.View())
 	button := &blurredButton
-	if m.focusIndex == len(m.inputs) {
+	if m.focusIndex == m.submitIndex() {
 		button = &focusedButton
 	}
 	fmt.Fprintf(&b, "\n\n%s\n\n", *button)
 
-	if m.wrongInput == true {
+	if m.wrongDate == true {
+		b.WriteString(helpStyle.Render("Pick a day up to today, written as " + dayFormat))
+	} else if m.saveError != "" {
+		b.WriteString(helpStyle.Render("Couldn't save the entry: " + m.saveError))
+	} else if m.wrongInput == true {
 		b.WriteString(helpStyle.Render("Wrong input for field "))
 		b.WriteString(helpStyle.Render(m.metrics[m.wrongIndex]))
+		b.WriteString(helpStyle.Render(": " + m.wrongReason))
+	} else {
+		b.WriteString(lipgloss.NewStyle().Faint(true).Render("Leave a field empty if you didn't track it, or enter " + skipped + " to skip it."))
 	}
 	return b.String()
 }
@@ -420,6 +635,14 @@ func updateMenu(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "enter", "l":
 			// open chosenView
 			m.chosen = true
+			if m.cursor1 == 1 {
+				// the entry form always starts out on today
+				m.loadEntryDate(time.Now())
+				m.focusIndex = 0
+				m.wrongInput = false
+				m.saveError = ""
+				return m, m.focusEntryField()
+			}
 		}
 	}
 	return m, nil
@@ -434,6 +657,9 @@ func updateChosen(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 	case 1:
 		m, cmd := updateEntry(m, msg)
 		return m, cmd
+	case 2:
+		m, cmd := updateCorrelation(m, msg)
+		return m, cmd
 	}
 	return m, nil
 }
@@ -475,6 +701,20 @@ func updateCalendar(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case "b":
 			m.chosen = false
+		case "d", "enter":
+			m.showDay = !m.showDay
+		case "s":
+			m.showStats = !m.showStats
+		case "r":
+			m.statsRange = (m.statsRange + 1) % len(statsRanges)
+		case "[":
+			m.calendarDay = m.calendarDay.AddDate(0, 0, -1)
+		case "]":
+			m.calendarDay = m.calendarDay.AddDate(0, 0, 1)
+		case "{":
+			m.calendarDay = m.calendarDay.AddDate(0, 0, -7)
+		case "}":
+			m.calendarDay = m.calendarDay.AddDate(0, 0, 7)
 		case "1":
 			m.cursor2 = 0
 		case "2":
@@ -510,6 +750,10 @@ func updateCalendar(m model, msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.cursor2 = 8
 			}
 		}
+		// nothing to see in the future
+		if today := dayStart(time.Now()); m.calendarDay.After(today) {
+			m.calendarDay = today
+		}
 	}
 	return m, nil
 }
@@ -600,15 +844,18 @@ func renderGrid(colorGrid [][]string) {
 	fmt.Println(docStyle.Render(doc.String()))
 }
 
-// render grid as year view
-func prerenderGrid(colorGrid [][]string) string {
+// render grid as year view, the cell at row and col gets marked
+func prerenderGrid(colorGrid [][]string, row int, col int, markColor string) string {
 	doc := strings.Builder{}
 	physicalWidth, _, _ := term.GetSize(int(os.Stdout.Fd()))
 	b := strings.Builder{}
-	for _, x := range colorGrid {
-		for _, y := range x {
+	for i, x := range colorGrid {
+		for j, y := range x {
 			// s := lipgloss.NewStyle().SetString(" ").Background(lipgloss.Color(y))
 			s := lipgloss.NewStyle().SetString("").Foreground(lipgloss.Color(y))
+			if i == row && j == col {
+				s = s.Background(lipgloss.Color(markColor))
+			}
 			b.WriteString(s.String())
 			w := lipgloss.NewStyle().SetString(" ")
 			b.WriteString(w.String())
@@ -635,8 +882,10 @@ func createGrid(data EntryData, format string, startDate time.Time, metric int)
 	sizeY := 7
 	if format == "month" {
 		sizeX, numOfDays = prepareMonthView(numOfDays, sizeX, startDate)
+		firstDay = time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, time.Local).Weekday()
 	} else if format == "year" {
 		sizeX, numOfDays = prepareYearView(numOfDays, sizeX, startDate)
+		firstDay = time.Date(startDate.Year(), 1, 1, 0, 0, 0, 0, time.Local).Weekday()
 	}
 	// got first weekday and length of year/month
 	// create the grid
@@ -668,6 +917,13 @@ func createGrid(data EntryData, format string, startDate time.Time, metric int)
 	return completeGrid
 }
 
+// where a day ends up in the year view, weeks start on monday
+func gridPosition(day time.Time) (int, int) {
+	offset := (int(time.Date(day.Year(), 1, 1, 0, 0, 0, 0, time.Local).Weekday()) + 6) % 7
+	index := offset + day.YearDay() - 1
+	return index % 7, index / 7
+}
+
 func prepareMonthView(numOfDays int, sizeX int, startDate time.Time) (int, int) {
 	// get year
 	year := startDate.Format("02-01-2006")
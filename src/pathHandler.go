@@ -0,0 +1,102 @@
+package src
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ##############################
+// ### FILE LOCATION HANDLING ###
+// ##############################
+
+var (
+	configPath string
+	dataPath   string
+)
+
+// written to the config location on first run so there is something to track
+const starterConfig = `[general]
+    BorderColor = "#98971a"
+    ActiveButtonColor = "#b16286"
+    ButtonColor = "#928374"
+    Backups = 5
+
+[[metrics]]
+    name = "Got up"
+    color1 = "#83a598"
+    color2 = "#abb31b"
+    rule = "time"
+
+[[metrics]]
+    name = "Mood"
+    color1 = "#83a598"
+    color2 = "#abb31b"
+    rule = "int10"
+`
+
+// SetPaths decides where config and data live.
+// Explicit paths (from flags) win over NIKKI_CONFIG/NIKKI_DATA,
+// which win over the XDG base directories.
+func SetPaths(config string, data string) {
+	configPath = resolvePath(config, "NIKKI_CONFIG", filepath.Join(xdgDir("XDG_CONFIG_HOME", ".config"), "nikki", "config.toml"))
+	dataPath = resolvePath(data, "NIKKI_DATA", filepath.Join(xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share")), "nikki", "data.json"))
+}
+
+// EnsurePaths creates the config and data directories and writes a
+// starter config if there is none yet.
+func EnsurePaths() error {
+	if configPath == "" || dataPath == "" {
+		SetPaths("", "")
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dataPath), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return os.WriteFile(configPath, []byte(starterConfig), 0644)
+	} else {
+		return err
+	}
+}
+
+func resolvePath(explicit string, envVar string, fallback string) string {
+	if explicit != "" {
+		return explicit
+	}
+	if env := os.Getenv(envVar); env != "" {
+		return env
+	}
+	return fallback
+}
+
+// returns $<envVar> or ~/<homeFallback> as the spec says to do when it's unset
+func xdgDir(envVar string, homeFallback string) string {
+	if dir := os.Getenv(envVar); dir != "" && filepath.IsAbs(dir) {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		// no home to speak of, use the working directory like we used to
+		return "."
+	}
+	return filepath.Join(home, homeFallback)
+}
+
+// the file the configured store keeps its data in
+func storePath() string {
+	if ReadConfig().General.Storage == "sqlite" {
+		return sqlitePath()
+	}
+	return dataPath
+}
+
+// the sqlite database sits next to where data.json would be
+func sqlitePath() string {
+	if strings.HasSuffix(dataPath, ".json") {
+		return strings.TrimSuffix(dataPath, ".json") + ".db"
+	}
+	return dataPath
+}
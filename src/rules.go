@@ -1,88 +1,710 @@
 package src
 
 import (
-	"regexp"
+	"fmt"
+	"github.com/lucasb-eyer/go-colorful"
+	"math"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
-var rules map[string]string = map[string]string{
-	"int10": `^[0-9]$`,
-	"int":   `^[0-9]+$`,
-	"bool":  `^0|1$`,
-	"goal":  `^goal (int|time)$`, // i want to have the option to provide a goal in different formats that is then compared with input values
+// Rule is a type of value a metric can track. The rule field of a metric in
+// config.toml picks one by the name it is registered under in ruleTypes.
+type Rule interface {
+	// Validate reports whether input is a value of this type.
+	Validate(input string) bool
+	// Parse turns a valid input into a number for colors and statistics.
+	Parse(input string) (float64, error)
+	// Format turns a number back into what the user would have typed.
+	Format(value float64) string
+	// Hint tells the user what to type, e.g. as input placeholder.
+	Hint() string
 }
 
-func ruleChecker(input string, rule string) bool {
-	// fmt.Printf("input: %v, rule: %v\n", input, rule)
-	val, ok := rules[rule]
-	if ok {
-		// match regex
-		if rule == "int10" {
-			i, err := strconv.Atoi(input)
+// Picker is a rule whose values are picked with single keys in the entry
+// form instead of being typed out.
+type Picker interface {
+	Rule
+	// Pick returns the value key turns current into, ok is false if key
+	// doesn't pick anything.
+	Pick(current string, key string) (value string, ok bool)
+	// Render shows value in the entry form.
+	Render(value string) string
+}
+
+// Normalizer is a rule that takes several spellings of a value and stores
+// just one of them.
+type Normalizer interface {
+	Rule
+	Normalize(input string) (string, error)
+}
+
+// Colorer is a rule whose values have colors of their own instead of
+// a spot between color1 and color2.
+type Colorer interface {
+	Color(value string) (string, bool)
+}
+
+// circular is a rule whose values go around, like times of day. Statistics
+// need it cut open somewhere, by default opposite the values' circular mean.
+type circular interface {
+	wrapAround(values []string) Rule
+}
+
+// the rule to take statistics of values with
+func statsRule(rule Rule, values []string) Rule {
+	if c, ok := rule.(circular); ok {
+		return c.wrapAround(values)
+	}
+	return rule
+}
+
+// configurable is a rule that takes options from the metric's config block
+type configurable interface {
+	configure(metric Metric) (Rule, error)
+}
+
+// Ranged is a rule that can have fixed bounds, its colors span those bounds
+// instead of the values tracked so far. ok is false while it has none.
+type Ranged interface {
+	Range() (min float64, max float64, ok bool)
+}
+
+// every rule a metric can use, adding a value type means adding it here
+var ruleTypes = map[string]Rule{
+	"int10":    intRule{min: 1, max: 10},
+	"int":      intRule{min: 0, max: math.MaxInt},
+	"range":    intRule{min: 0, max: math.MaxInt, needsBounds: true},
+	"bool":     boolRule{},
+	"time":     timeRule{},
+	"float":    floatRule{decimals: 2, min: math.Inf(-1), max: math.Inf(1)},
+	"duration": durationRule{},
+	"choice":   choiceRule{},
+}
+
+// options a rule can take in parentheses, positional ones in this order,
+// e.g. range(1,5) or int(min=0,max=20)
+var ruleParams = []string{"min", "max", "decimals"}
+
+// splits a rule like int(min=0,max=20) into its name and options
+func parseRuleSpec(spec string) (string, map[string]string, error) {
+	spec = strings.TrimSpace(spec)
+	name, args, ok := strings.Cut(spec, "(")
+	if !ok {
+		return spec, nil, nil
+	}
+	if !strings.HasSuffix(args, ")") {
+		return "", nil, fmt.Errorf("rule %q is missing a closing parenthesis", spec)
+	}
+	params := map[string]string{}
+	args = strings.TrimSuffix(args, ")")
+	if strings.TrimSpace(args) == "" {
+		return strings.TrimSpace(name), params, nil
+	}
+	for idx, arg := range strings.Split(args, ",") {
+		key, value, named := strings.Cut(arg, "=")
+		if !named {
+			if idx >= len(ruleParams) {
+				return "", nil, fmt.Errorf("rule %q has too many options", spec)
+			}
+			key, value = ruleParams[idx], arg
+		}
+		key = strings.TrimSpace(key)
+		if !contains(ruleParams, key) {
+			return "", nil, fmt.Errorf("rule %q: unknown option %q, use %v", spec, key, strings.Join(ruleParams, ", "))
+		}
+		if _, ok := params[key]; ok {
+			return "", nil, fmt.Errorf("rule %q sets %v twice", spec, key)
+		}
+		params[key] = strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(name), params, nil
+}
+
+// fills the metric's options from the ones written in its rule
+func applyRuleParams(metric Metric, params map[string]string) (Metric, error) {
+	for key, value := range params {
+		if key == "decimals" {
+			decimals, err := strconv.Atoi(value)
 			if err != nil {
-				// wtf are you doing
-				return false
+				return metric, fmt.Errorf("decimals %q is not a whole number", value)
+			}
+			if metric.Decimals != nil {
+				return metric, fmt.Errorf("decimals is set in the rule and on its own")
 			}
-			input = strconv.Itoa(i - 1)
+			metric.Decimals = &decimals
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return metric, fmt.Errorf("%v %q is not a number", key, value)
 		}
-		var validInput = regexp.MustCompile(val)
-		if validInput.MatchString(input) {
-			// youre good to go
-			return true
+		bound := number(parsed)
+		if key == "min" {
+			if metric.Min != nil {
+				return metric, fmt.Errorf("min is set in the rule and on its own")
+			}
+			metric.Min = &bound
 		} else {
-			// aw hell naw
-			return false
+			if metric.Max != nil {
+				return metric, fmt.Errorf("max is set in the rule and on its own")
+			}
+			metric.Max = &bound
 		}
-	} else {
-		return false
 	}
+	return metric, nil
 }
 
-func reformatData(data string, MetricInfo string) int {
-	if MetricInfo == "int10" {
-		formattedData, err := strconv.Atoi(data)
-		if err != nil {
-			panic(err)
+// looks up the rule registered under name
+func lookupRule(name string) (Rule, error) {
+	rule, ok := ruleTypes[name]
+	if !ok {
+		known := []string{}
+		for key := range ruleTypes {
+			known = append(known, key)
+		}
+		sort.Strings(known)
+		return nil, fmt.Errorf("unknown rule %q, use one of %v", name, strings.Join(known, ", "))
+	}
+	return rule, nil
+}
+
+// the value as it is stored, valid input in whatever spelling goes in
+func (metric Metric) normalize(value string) string {
+	rule, err := metric.rule()
+	if err != nil || value == "" || value == skipped {
+		return value
+	}
+	if normalizer, ok := rule.(Normalizer); ok {
+		if normalized, err := normalizer.Normalize(value); err == nil {
+			return normalized
+		}
+	}
+	return value
+}
+
+// the value as the user would write it
+func (metric Metric) display(value string) string {
+	rule, err := metric.rule()
+	if err != nil || value == "" || value == skipped {
+		return value
+	}
+	if _, ok := rule.(Normalizer); ok {
+		if parsed, err := rule.Parse(value); err == nil {
+			return rule.Format(parsed)
 		}
-		return formattedData
-	} else if MetricInfo == "int" {
-		formattedData, err := strconv.Atoi(data)
+	}
+	return value
+}
 
+// the rule the metric's values follow, set up with the metric's options
+func (metric Metric) rule() (Rule, error) {
+	spec := metric.Rule
+	if spec == "" && metric.derived() {
+		spec = "float"
+	}
+	name, params, err := parseRuleSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("metric %v: %v", metric.Name, err)
+	}
+	rule, err := lookupRule(name)
+	if err != nil {
+		return nil, fmt.Errorf("metric %v: %v", metric.Name, err)
+	}
+	if len(params) > 0 {
+		if _, ok := rule.(configurable); !ok {
+			return nil, fmt.Errorf("metric %v: rule %v takes no options", metric.Name, name)
+		}
+		metric, err = applyRuleParams(metric, params)
+		if err != nil {
+			return nil, fmt.Errorf("metric %v: %v", metric.Name, err)
+		}
+	}
+	if c, ok := rule.(configurable); ok {
+		rule, err = c.configure(metric)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("metric %v: %v", metric.Name, err)
 		}
-		return formattedData
-	} else if MetricInfo == "time" {
-		data = data[0:2] + data[3:5]
-		formattedData, err := strconv.Atoi(data)
+	}
+	return rule, nil
+}
+
+// what the metric's rule resolves to, however it is written in the config,
+// e.g. "bool()" or " bool" are yes/no habits too
+func (metric Metric) isBool() bool {
+	rule, err := metric.rule()
+	_, ok := rule.(boolRule)
+	return err == nil && ok
+}
+
+func (metric Metric) isChoice() bool {
+	rule, err := metric.rule()
+	_, ok := rule.(choiceRule)
+	return err == nil && ok
+}
+
+func (metric Metric) isDuration() bool {
+	rule, err := metric.rule()
+	_, ok := rule.(durationRule)
+	return err == nil && ok
+}
+
+// whether a value of a metric without a goal counts as done. For choices
+// that are only the ones in counts, anything tracked counts otherwise.
+func (metric Metric) countsAsDone(value string) bool {
+	rule, err := metric.rule()
+	if choice, ok := rule.(choiceRule); err == nil && ok {
+		return contains(choice.counts, value)
+	}
+	return true
+}
+
+// hints read like "1 to 5" or "0 or more"
+func outOfRange(input string, hint string) error {
+	if low, high, ok := strings.Cut(hint, " to "); ok {
+		return fmt.Errorf("%v is not between %v and %v", input, low, high)
+	}
+	return fmt.Errorf("%v is not %v", input, hint)
+}
 
+// whole numbers from min to max
+type intRule struct {
+	min         int
+	max         int
+	needsBounds bool // range has to be given both
+}
+
+func (r intRule) configure(metric Metric) (Rule, error) {
+	if r.needsBounds && (metric.Min == nil || metric.Max == nil) {
+		return nil, fmt.Errorf("a range needs a min and a max, e.g. range(1,5)")
+	}
+	for _, bound := range []*number{metric.Min, metric.Max} {
+		if bound != nil && float64(*bound) != math.Trunc(float64(*bound)) {
+			return nil, fmt.Errorf("bound %v is not a whole number", *bound)
+		}
+	}
+	if metric.Min != nil {
+		r.min = int(*metric.Min)
+	}
+	if metric.Max != nil {
+		r.max = int(*metric.Max)
+	}
+	if r.min > r.max {
+		return nil, fmt.Errorf("min %v is above max %v", r.min, r.max)
+	}
+	return r, nil
+}
+
+func (r intRule) Validate(input string) bool {
+	_, err := r.Parse(input)
+	return err == nil
+}
+
+func (r intRule) Parse(input string) (float64, error) {
+	value, err := strconv.Atoi(input)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a whole number", input)
+	}
+	if value < r.min || value > r.max {
+		return 0, outOfRange(input, r.Hint())
+	}
+	return float64(value), nil
+}
+
+func (r intRule) Format(value float64) string {
+	return strconv.Itoa(int(math.Round(value)))
+}
+
+func (r intRule) Range() (float64, float64, bool) {
+	return float64(r.min), float64(r.max), r.max != math.MaxInt
+}
+
+func (r intRule) Hint() string {
+	if r.max == math.MaxInt {
+		return fmt.Sprintf("%d or more", r.min)
+	}
+	return fmt.Sprintf("%d to %d", r.min, r.max)
+}
+
+// a time of day as hh:mm, parsed to minutes since midnight. Times before the
+// wrap point belong to the day before, so with a wrap at 18:00 00:30 comes
+// after 23:30 and parses to 24:30.
+type timeRule struct {
+	wrap    int
+	wrapSet bool
+}
+
+const minutesPerDay = 24 * 60
+
+func (r timeRule) configure(metric Metric) (Rule, error) {
+	if metric.Wrap == "" {
+		return r, nil
+	}
+	wrap, err := timeRule{}.Parse(metric.Wrap)
+	if err != nil {
+		return nil, fmt.Errorf("wrap: %v", err)
+	}
+	return timeRule{wrap: int(wrap), wrapSet: true}, nil
+}
+
+// without a wrap point the day starts opposite the circular mean, where
+// the fewest values should be
+func (r timeRule) wrapAround(values []string) Rule {
+	if r.wrapSet {
+		return r
+	}
+	var x, y float64
+	for _, value := range values {
+		minutes, err := timeRule{}.Parse(value)
 		if err != nil {
-			panic(err)
+			continue
+		}
+		angle := minutes / minutesPerDay * 2 * math.Pi
+		x += math.Cos(angle)
+		y += math.Sin(angle)
+	}
+	if x == 0 && y == 0 {
+		return r
+	}
+	mean := math.Atan2(y, x) / (2 * math.Pi) * minutesPerDay
+	wrap := int(math.Round(mean+minutesPerDay/2)) % minutesPerDay
+	if wrap < 0 {
+		wrap += minutesPerDay
+	}
+	return timeRule{wrap: wrap, wrapSet: true}
+}
+
+func (r timeRule) Validate(input string) bool {
+	_, err := r.Parse(input)
+	return err == nil
+}
+
+func (r timeRule) Parse(input string) (float64, error) {
+	if len(input) != 5 || input[2] != ':' {
+		return 0, fmt.Errorf("%q is not written as hh:mm", input)
+	}
+	hours, err := strconv.Atoi(input[0:2])
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.Atoi(input[3:5])
+	if err != nil {
+		return 0, err
+	}
+	if hours < 0 || hours > 23 || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("%v is not a time of day", input)
+	}
+	value := hours*60 + minutes
+	if value < r.wrap {
+		value += minutesPerDay
+	}
+	return float64(value), nil
+}
+
+func (r timeRule) Format(value float64) string {
+	minutes := int(math.Round(value)) % minutesPerDay
+	if minutes < 0 {
+		minutes += minutesPerDay
+	}
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+func (r timeRule) Hint() string {
+	return "hh:mm"
+}
+
+// done or not done, stored as 1 and 0 and flipped with space
+type boolRule struct{}
+
+func (r boolRule) Validate(input string) bool {
+	return input == "0" || input == "1"
+}
+
+func (r boolRule) Parse(input string) (float64, error) {
+	if !r.Validate(input) {
+		return 0, fmt.Errorf("%q is neither 0 nor 1", input)
+	}
+	return strconv.ParseFloat(input, 64)
+}
+
+func (r boolRule) Format(value float64) string {
+	if value >= 0.5 {
+		return "1"
+	}
+	return "0"
+}
+
+func (r boolRule) Hint() string {
+	return "space to toggle"
+}
+
+func (r boolRule) Pick(current string, key string) (string, bool) {
+	switch key {
+	case " ":
+		if current == "1" {
+			return "0", true
 		}
+		return "1", true
+	case "y":
+		return "1", true
+	case "n":
+		return "0", true
+	}
+	return current, false
+}
 
-		return formattedData
-	} else {
-		panic("YOOOOOOOOOOOOOOOOO")
+func (r boolRule) Render(value string) string {
+	switch value {
+	case "1":
+		return "[x] done"
+	case "0":
+		return "[ ] not done"
+	case skipped:
+		return "[-] skipped"
 	}
+	return "[ ]"
 }
 
-func formatData(data int, MetricInfo string) string {
-	if MetricInfo == "int10" {
-		formattedData := strconv.Itoa(data)
-		return formattedData
-	} else if MetricInfo == "int" {
-		formattedData := strconv.Itoa(data)
-		return formattedData
-	} else if MetricInfo == "time" {
-		formattedData := strconv.Itoa(data)
-		var formattedTime string
-		if len(formattedData) < 4 {
-			formattedTime = "0" + formattedData[0:1] + ":" + formattedData[1:3]
-		} else {
-			formattedTime = formattedData[0:2] + ":" + formattedData[2:4]
+func (r boolRule) Range() (float64, float64, bool) {
+	return 0, 1, true
+}
+
+// decimal numbers like 7.5, with decimals digits after the point at most
+type floatRule struct {
+	decimals int
+	min      float64
+	max      float64
+}
+
+func (r floatRule) configure(metric Metric) (Rule, error) {
+	if metric.Decimals != nil {
+		if *metric.Decimals < 0 {
+			return nil, fmt.Errorf("decimals can't be negative")
+		}
+		r.decimals = *metric.Decimals
+	}
+	if metric.Min != nil {
+		r.min = float64(*metric.Min)
+	}
+	if metric.Max != nil {
+		r.max = float64(*metric.Max)
+	}
+	if r.min > r.max {
+		return nil, fmt.Errorf("min %v is above max %v", r.min, r.max)
+	}
+	return r, nil
+}
+
+func (r floatRule) Validate(input string) bool {
+	_, err := r.Parse(input)
+	return err == nil
+}
+
+func (r floatRule) Parse(input string) (float64, error) {
+	value, err := strconv.ParseFloat(input, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, fmt.Errorf("%q is not a number", input)
+	}
+	if point := strings.Index(input, "."); point >= 0 && len(input)-point-1 > r.decimals {
+		return 0, fmt.Errorf("%v has more than %d decimals", input, r.decimals)
+	}
+	if value < r.min || value > r.max {
+		return 0, outOfRange(input, r.Hint())
+	}
+	return value, nil
+}
+
+func (r floatRule) Range() (float64, float64, bool) {
+	return r.min, r.max, !math.IsInf(r.min, 0) && !math.IsInf(r.max, 0)
+}
+
+func (r floatRule) Format(value float64) string {
+	return strconv.FormatFloat(value, 'f', r.decimals, 64)
+}
+
+func (r floatRule) Hint() string {
+	switch {
+	case !math.IsInf(r.min, 0) && !math.IsInf(r.max, 0):
+		return fmt.Sprintf("%v to %v", r.Format(r.min), r.Format(r.max))
+	case !math.IsInf(r.min, 0):
+		return fmt.Sprintf("%v or more", r.Format(r.min))
+	case !math.IsInf(r.max, 0):
+		return fmt.Sprintf("up to %v", r.Format(r.max))
+	}
+	return r.Format(0)
+}
+
+// a length of time like 1h30m, 1:30 or 90, stored as minutes
+type durationRule struct{}
+
+func (r durationRule) Validate(input string) bool {
+	_, err := r.Parse(input)
+	return err == nil
+}
+
+func (r durationRule) Parse(input string) (float64, error) {
+	input = strings.ReplaceAll(strings.TrimSpace(input), " ", "")
+	// plain minutes
+	if minutes, err := strconv.Atoi(input); err == nil {
+		if minutes < 0 {
+			return 0, fmt.Errorf("%v is negative", input)
+		}
+		return float64(minutes), nil
+	}
+	// hours:minutes
+	if hours, minutes, ok := strings.Cut(input, ":"); ok {
+		h, err := strconv.Atoi(hours)
+		if err != nil || h < 0 {
+			return 0, fmt.Errorf("%q is not a duration", input)
+		}
+		m, err := strconv.Atoi(minutes)
+		if err != nil || m < 0 || m > 59 || len(minutes) != 2 {
+			return 0, fmt.Errorf("%q is not a duration", input)
 		}
-		return formattedTime
-	} else {
-		panic("eroooooooooooo")
+		return float64(h*60 + m), nil
+	}
+	// 1h30m, 25m, 2h
+	duration, err := time.ParseDuration(input)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("%q is not a duration", input)
+	}
+	return math.Round(duration.Minutes()), nil
+}
+
+func (r durationRule) Normalize(input string) (string, error) {
+	minutes, err := r.Parse(input)
+	if err != nil {
+		return input, err
+	}
+	return strconv.Itoa(int(minutes)), nil
+}
+
+func (r durationRule) Format(value float64) string {
+	minutes := int(math.Round(value))
+	switch {
+	case minutes >= 60 && minutes%60 != 0:
+		return fmt.Sprintf("%dh%dm", minutes/60, minutes%60)
+	case minutes >= 60:
+		return fmt.Sprintf("%dh", minutes/60)
+	}
+	return fmt.Sprintf("%dm", minutes)
+}
+
+func (r durationRule) Hint() string {
+	return "1h30m, 1:30 or 90"
+}
+
+// one of the options listed in the metric's choices, stored by name
+type choiceRule struct {
+	options []string
+	colors  []string
+	counts  []string // the options that count as done
+}
+
+func (r choiceRule) configure(metric Metric) (Rule, error) {
+	if len(metric.Choices) == 0 {
+		return nil, fmt.Errorf("a choice metric needs choices = [...]")
+	}
+	seen := map[string]bool{}
+	for _, option := range metric.Choices {
+		if option == "" || option == skipped || seen[option] {
+			return nil, fmt.Errorf("choice %q is empty, reserved or listed twice", option)
+		}
+		seen[option] = true
+	}
+	r.options = metric.Choices
+	r.colors = metric.Colors
+	// the first choice is usually the "didn't" one, e.g. a workout of "none"
+	r.counts = metric.Choices[1:]
+	if len(metric.Counts) > 0 {
+		r.counts = metric.Counts
+	}
+	for _, option := range r.counts {
+		if !seen[option] {
+			return nil, fmt.Errorf("counts lists %q, which is none of the choices", option)
+		}
+	}
+	if len(r.colors) == 0 {
+		// spread the options between the metric's two colors
+		from, _ := colorful.Hex(metric.Color1)
+		to, _ := colorful.Hex(metric.Color2)
+		for idx := range r.options {
+			r.colors = append(r.colors, from.BlendLuv(to, float64(idx)/math.Max(1, float64(len(r.options)-1))).Hex())
+		}
+	}
+	if len(r.colors) != len(r.options) {
+		return nil, fmt.Errorf("%d colors for %d choices", len(r.colors), len(r.options))
+	}
+	return r, nil
+}
+
+func (r choiceRule) Validate(input string) bool {
+	_, err := r.Parse(input)
+	return err == nil
+}
+
+// the position of the option, so goals and stats have a number to work with
+func (r choiceRule) Parse(input string) (float64, error) {
+	for idx, option := range r.options {
+		if option == input {
+			return float64(idx), nil
+		}
+	}
+	return 0, fmt.Errorf("%q is none of %v", input, strings.Join(r.options, ", "))
+}
+
+func (r choiceRule) Format(value float64) string {
+	idx := int(math.Round(value))
+	if idx < 0 || idx >= len(r.options) {
+		return ""
+	}
+	return r.options[idx]
+}
+
+func (r choiceRule) Hint() string {
+	return "←/→ or 1-9 to pick"
+}
+
+func (r choiceRule) Pick(current string, key string) (string, bool) {
+	idx, err := r.Parse(current)
+	switch key {
+	case "right", "l":
+		if err != nil {
+			return r.options[0], true
+		}
+		return r.options[(int(idx)+1)%len(r.options)], true
+	case "left", "h":
+		if err != nil {
+			return r.options[len(r.options)-1], true
+		}
+		return r.options[(int(idx)+len(r.options)-1)%len(r.options)], true
+	}
+	if number, err := strconv.Atoi(key); err == nil && number >= 1 && number <= len(r.options) {
+		return r.options[number-1], true
+	}
+	return current, false
+}
+
+func (r choiceRule) Render(value string) string {
+	if value == skipped {
+		return "skipped"
+	}
+	options := []string{}
+	for _, option := range r.options {
+		if option == value {
+			option = "[" + option + "]"
+		}
+		options = append(options, option)
+	}
+	return strings.Join(options, " ")
+}
+
+func (r choiceRule) Color(value string) (string, bool) {
+	idx, err := r.Parse(value)
+	if err != nil {
+		return "", false
 	}
+	return r.colors[int(idx)], true
 }
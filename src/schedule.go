@@ -0,0 +1,175 @@
+package src
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ###############
+// ## SCHEDULES ##
+// ###############
+
+// when a habit is due: on some weekdays ("mon wed fri"), every few days
+// ("every 2 days") or a number of times per week ("3 times per week")
+type schedule struct {
+	weekdays map[time.Weekday]bool
+	every    int
+	start    time.Time // the day every is counted from
+	perWeek  int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday, "thu": time.Thursday,
+	"fri": time.Friday, "sat": time.Saturday, "sun": time.Sunday,
+}
+
+func parseSchedule(spec string) (schedule, error) {
+	fields := strings.Fields(strings.ToLower(strings.ReplaceAll(spec, ",", " ")))
+	if len(fields) == 0 {
+		return schedule{}, fmt.Errorf("schedule is empty")
+	}
+	// every 2 days
+	if fields[0] == "every" {
+		if len(fields) != 3 || (fields[2] != "days" && fields[2] != "day") {
+			return schedule{}, fmt.Errorf("schedule %q should read like \"every 2 days\"", spec)
+		}
+		every, err := strconv.Atoi(fields[1])
+		if err != nil || every < 1 {
+			return schedule{}, fmt.Errorf("schedule %q: %q is not a number of days", spec, fields[1])
+		}
+		return schedule{every: every}, nil
+	}
+	// 3 times per week
+	if times, err := strconv.Atoi(fields[0]); err == nil {
+		rest := strings.Join(fields[1:], " ")
+		if rest != "times per week" && rest != "per week" && rest != "times a week" {
+			return schedule{}, fmt.Errorf("schedule %q should read like \"3 times per week\"", spec)
+		}
+		if times < 1 || times > 7 {
+			return schedule{}, fmt.Errorf("schedule %q: a week has 7 days", spec)
+		}
+		return schedule{perWeek: times}, nil
+	}
+	// mon wed fri
+	weekdays := map[time.Weekday]bool{}
+	for _, field := range fields {
+		if len(field) < 3 {
+			return schedule{}, fmt.Errorf("schedule %q: unknown day %q", spec, field)
+		}
+		weekday, ok := weekdayNames[field[:3]]
+		if !ok {
+			return schedule{}, fmt.Errorf("schedule %q: unknown day %q", spec, field)
+		}
+		weekdays[weekday] = true
+	}
+	return schedule{weekdays: weekdays}, nil
+}
+
+// the schedule of the metric, ok is false for daily metrics. Every few days
+// is counted from the first day the metric was tracked.
+func (metric Metric) schedule(data EntryData) (s schedule, ok bool, err error) {
+	if metric.Schedule == "" {
+		return schedule{}, false, nil
+	}
+	s, err = parseSchedule(metric.Schedule)
+	if err != nil {
+		return schedule{}, false, fmt.Errorf("metric %v: %v", metric.Name, err)
+	}
+	if s.every > 0 {
+		dates, _ := data.series(metric.key())
+		if len(dates) > 0 {
+			s.start = dates[0]
+		}
+	}
+	return s, true, nil
+}
+
+// whether the habit is due on day. With a number of times per week every day
+// is a chance to do it.
+func (s schedule) scheduled(day time.Time) bool {
+	switch {
+	case len(s.weekdays) > 0:
+		return s.weekdays[day.Weekday()]
+	case s.every > 0:
+		if s.start.IsZero() {
+			return true
+		}
+		return daysBetween(s.start, day)%s.every == 0
+	}
+	return true
+}
+
+// how many times the habit was due from from to to, both included
+func (s schedule) occurrences(from time.Time, to time.Time) int {
+	if s.perWeek > 0 {
+		weeks := daysBetween(weekStart(from), weekStart(to))/7 + 1
+		return weeks * s.perWeek
+	}
+	count := 0
+	for day := dayStart(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		if s.scheduled(day) {
+			count++
+		}
+	}
+	return count
+}
+
+// the monday of the week day is in
+func weekStart(day time.Time) time.Time {
+	return dayStart(day).AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// whole days from a to b, negative if b comes first
+func daysBetween(a time.Time, b time.Time) int {
+	a, b = dayStart(a), dayStart(b)
+	// dates, not hours, so daylight saving time doesn't get in the way
+	return int(time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC).
+		Sub(time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)).Hours() / 24)
+}
+
+// what lies between two days that count towards a streak
+type streakGaps struct {
+	skipped  map[string]bool
+	sched    schedule
+	hasSched bool
+	weekDone map[string]int // days that counted per week, keyed by its monday
+}
+
+func newStreakGaps(data EntryData, metric int, dates []time.Time) streakGaps {
+	gaps := streakGaps{
+		skipped:  data.skippedDays(data.Metrics[metric].key()),
+		weekDone: map[string]int{},
+	}
+	gaps.sched, gaps.hasSched, _ = data.Metrics[metric].schedule(data)
+	for _, date := range dates {
+		gaps.weekDone[dayKey(weekStart(date))]++
+	}
+	return gaps
+}
+
+// how many days strictly between from and to were missed, skipped days and
+// days the habit wasn't due don't count. With a number of times per week it
+// is the weeks that fell short instead.
+func (g streakGaps) missed(from time.Time, to time.Time) int {
+	missed := 0
+	if g.hasSched && g.sched.perWeek > 0 {
+		for week := weekStart(from); week.Before(weekStart(to)); week = week.AddDate(0, 0, 7) {
+			if g.weekDone[dayKey(week)] < g.sched.perWeek {
+				missed++
+			}
+		}
+		return missed
+	}
+	for day := dayStart(from).AddDate(0, 0, 1); daysBetween(day, to) > 0; day = day.AddDate(0, 0, 1) {
+		if g.skipped[dayKey(day)] {
+			continue
+		}
+		if g.hasSched && !g.sched.scheduled(day) {
+			continue
+		}
+		missed++
+	}
+	return missed
+}
@@ -0,0 +1,310 @@
+package src
+
+import (
+	"database/sql"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"os"
+	"strings"
+	"time"
+)
+
+// ####################
+// ## SQLITE BACKEND ##
+// ####################
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS metrics (
+	idx    INTEGER PRIMARY KEY,
+	name   TEXT NOT NULL,
+	rule   TEXT NOT NULL,
+	color1 TEXT NOT NULL,
+	color2 TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS entries (
+	day    TEXT NOT NULL,
+	metric TEXT NOT NULL,
+	value  TEXT NOT NULL,
+	logged TEXT NOT NULL,
+	PRIMARY KEY (day, metric)
+);`
+
+// schema changes since the first version, applied in order and tracked in
+// user_version. Keep appending, never edit a step that has shipped.
+var sqliteMigrations = []string{
+	// metrics get stable IDs, entries are keyed by them instead of names
+	`ALTER TABLE metrics ADD COLUMN id INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE metrics ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
+	UPDATE metrics SET id = idx + 1;
+	UPDATE entries SET metric = (SELECT CAST(id AS TEXT) FROM metrics WHERE metrics.name = entries.metric)
+		WHERE metric IN (SELECT name FROM metrics);`,
+	// diary notes, one per day
+	`CREATE TABLE notes (
+		day  TEXT PRIMARY KEY,
+		note TEXT NOT NULL
+	);`,
+}
+
+// keeps one row per metric and day, so a submit only touches that day
+type sqliteStore struct {
+	db   *sql.DB
+	path string
+}
+
+func openSQLiteStore(path string) (*sqliteStore, error) {
+	// back up once per run, writes after that are transactional anyway
+	if err := backupData(path, backupCount()); err != nil {
+		return nil, err
+	}
+	_, statErr := os.Stat(path)
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	s := &sqliteStore{db: db, path: path}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	// a fresh database picks up whatever was tracked in data.json so far
+	if os.IsNotExist(statErr) {
+		if err := s.importJSON(); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *sqliteStore) migrate() error {
+	var version int
+	if err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(sqliteMigrations); version++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[version]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		// pragmas can't take parameters
+		if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) importJSON() error {
+	if dataPath == s.path {
+		return nil
+	}
+	if _, err := os.Stat(dataPath); err != nil {
+		return nil
+	}
+	data, err := loadJSON()
+	if err != nil {
+		return err
+	}
+	return s.Save(data)
+}
+
+func (s *sqliteStore) LoadMetrics() ([]Metric, error) {
+	rows, err := s.db.Query(`SELECT id, name, rule, color1, color2, archived FROM metrics ORDER BY idx`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	metrics := []Metric{}
+	for rows.Next() {
+		var metric Metric
+		if err := rows.Scan(&metric.ID, &metric.Name, &metric.Rule, &metric.Color1, &metric.Color2, &metric.Archived); err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, rows.Err()
+}
+
+func (s *sqliteStore) Load() (EntryData, error) {
+	return s.query("")
+}
+
+func (s *sqliteStore) Range(from time.Time, to time.Time) (EntryData, error) {
+	return s.query(`WHERE day BETWEEN ? AND ?`, dayKey(from), dayKey(to))
+}
+
+// builds EntryData out of the metrics table and the entries and notes
+// of the days the where clause selects
+func (s *sqliteStore) query(where string, args ...any) (EntryData, error) {
+	data := newEntryData()
+	metrics, err := s.LoadMetrics()
+	if err != nil {
+		return data, err
+	}
+	data.Metrics = metrics
+
+	rows, err := s.db.Query(`SELECT day, metric, value FROM entries `+where, args...)
+	if err != nil {
+		return data, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day, metric, value string
+		if err := rows.Scan(&day, &metric, &value); err != nil {
+			return data, err
+		}
+		if data.Days[day] == nil {
+			data.Days[day] = map[string]string{}
+		}
+		data.Days[day][metric] = value
+	}
+	if err := rows.Err(); err != nil {
+		return data, err
+	}
+
+	notes, err := s.db.Query(`SELECT day, note FROM notes `+where, args...)
+	if err != nil {
+		return data, err
+	}
+	defer notes.Close()
+	for notes.Next() {
+		var day, note string
+		if err := notes.Scan(&day, &note); err != nil {
+			return data, err
+		}
+		data.Notes[day] = note
+	}
+	return data, notes.Err()
+}
+
+func (s *sqliteStore) Save(data EntryData) error {
+	if err := backupData(s.path, backupCount()); err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := putMetrics(tx, data.Metrics); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM entries`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM notes`); err != nil {
+		return err
+	}
+	for day, values := range data.Days {
+		for metric, value := range values {
+			if err := putEntry(tx, day, metric, value); err != nil {
+				return err
+			}
+		}
+	}
+	for day, note := range data.Notes {
+		if _, err := tx.Exec(`INSERT INTO notes (day, note) VALUES (?, ?)`, day, note); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) PutMetrics(metrics []Metric) error {
+	if err := backupData(s.path, backupCount()); err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := putMetrics(tx, metrics); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// replaces the metrics table, in the order given
+func putMetrics(tx *sql.Tx, metrics []Metric) error {
+	if _, err := tx.Exec(`DELETE FROM metrics`); err != nil {
+		return err
+	}
+	for idx, metric := range metrics {
+		if _, err := tx.Exec(`INSERT INTO metrics (idx, id, name, rule, color1, color2, archived) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			idx, metric.ID, metric.Name, metric.Rule, metric.Color1, metric.Color2, metric.Archived); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) GetDay(day time.Time) (map[string]string, error) {
+	rows, err := s.db.Query(`SELECT metric, value FROM entries WHERE day = ?`, dayKey(day))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := map[string]string{}
+	for rows.Next() {
+		var metric, value string
+		if err := rows.Scan(&metric, &value); err != nil {
+			return nil, err
+		}
+		values[metric] = value
+	}
+	return values, rows.Err()
+}
+
+func (s *sqliteStore) PutEntry(day time.Time, values map[string]string, note string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for metric, value := range values {
+		if value == "" {
+			if _, err := tx.Exec(`DELETE FROM entries WHERE day = ? AND metric = ?`, dayKey(day), metric); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := putEntry(tx, dayKey(day), metric, value); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(note) == "" {
+		_, err = tx.Exec(`DELETE FROM notes WHERE day = ?`, dayKey(day))
+	} else {
+		_, err = tx.Exec(`INSERT INTO notes (day, note) VALUES (?, ?)
+			ON CONFLICT (day) DO UPDATE SET note = excluded.note`, dayKey(day), note)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// logged only records when the value was written, days are what counts
+func putEntry(tx *sql.Tx, day string, metric string, value string) error {
+	_, err := tx.Exec(`INSERT INTO entries (day, metric, value, logged) VALUES (?, ?, ?, ?)
+		ON CONFLICT (day, metric) DO UPDATE SET value = excluded.value, logged = excluded.logged`,
+		day, metric, value, time.Now().Format(time.RFC3339Nano))
+	return err
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
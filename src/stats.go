@@ -0,0 +1,211 @@
+package src
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// ###########
+// ## STATS ##
+// ###########
+
+// what the stats panel can be computed over, r cycles through them
+var statsRanges = []string{"this year", "last 30 days", "last 90 days", "all time"}
+
+// the first and last day of a stats range as of today
+func statsRangeBounds(idx int, today time.Time) (time.Time, time.Time) {
+	today = dayStart(today)
+	switch statsRanges[idx] {
+	case "this year":
+		return time.Date(today.Year(), 1, 1, 0, 0, 0, 0, time.Local), today
+	case "last 30 days":
+		return today.AddDate(0, 0, -29), today
+	case "last 90 days":
+		return today.AddDate(0, 0, -89), today
+	}
+	return time.Time{}, today
+}
+
+// everything the stats panel shows about a metric, "-" where there is
+// nothing to show
+type metricStats struct {
+	count       int // days with a value
+	median      string
+	stddev      string
+	percentiles []string // 10th, 25th, 75th and 90th
+	done, due   int      // days that counted out of the days it was due
+	bestDay     string
+	worstDay    string
+	months      []monthStat
+}
+
+type monthStat struct {
+	month   time.Time
+	average string
+}
+
+var statsPercentiles = []float64{10, 25, 75, 90}
+
+// the stats of the metric over a range, the days in it are read from the
+// store and the rest of the history only decides what was due
+func getStats(store Store, data EntryData, metric int, rangeIdx int, now time.Time) (metricStats, error) {
+	from, to := statsRangeBounds(rangeIdx, now)
+	stats := metricStats{median: "-", stddev: "-", bestDay: "-", worstDay: "-"}
+	for range statsPercentiles {
+		stats.percentiles = append(stats.percentiles, "-")
+	}
+	rule, err := data.Metrics[metric].rule()
+	if err != nil {
+		return stats, nil
+	}
+	days, err := loadRange(store, data.Metrics, from, to)
+	if err != nil {
+		return stats, err
+	}
+	dates, values := days.series(data.Metrics[metric].key())
+	stats.count = len(values)
+	if len(dates) == 0 {
+		return stats, nil
+	}
+
+	// how often it counted, like a completion rate for habits
+	allDates, _ := data.series(data.Metrics[metric].key())
+	if allDates[0].After(from) {
+		from = allDates[0]
+	}
+	stats.due = daysBetween(from, to) + 1
+	if due, ok := dueBetween(data, metric, from, to); ok {
+		stats.due = due
+	}
+	counted := []time.Time{}
+	for _, date := range streakDates(data, metric) {
+		if !date.Before(from) && !date.After(to) {
+			counted = append(counted, date)
+		}
+	}
+	stats.done = len(counted)
+	if _, ok, _ := data.Metrics[metric].schedule(data); ok {
+		stats.done = countDue(data, metric, counted)
+	}
+
+	numeric := !data.Metrics[metric].isBool() && !data.Metrics[metric].isChoice()
+	scale := statsRule(rule, values)
+	parsed := []float64{}
+	for _, value := range values {
+		if number, err := scale.Parse(value); err == nil {
+			parsed = append(parsed, number)
+		}
+	}
+	if numeric && len(parsed) > 0 {
+		sorted := append([]float64{}, parsed...)
+		sort.Float64s(sorted)
+		stats.median = scale.Format(percentile(sorted, 50))
+		for idx, p := range statsPercentiles {
+			stats.percentiles[idx] = scale.Format(percentile(sorted, p))
+		}
+		stats.stddev = formatSpread(rule, stddev(parsed))
+	}
+
+	// weekdays by how good they went on average, goals and direction included
+	scores := calcRangeMap(data)[data.Metrics[metric].key()]
+	sums := map[time.Weekday]float64{}
+	counts := map[time.Weekday]int{}
+	for _, date := range dates {
+		if score, ok := scores[dayKey(date)]; ok {
+			sums[date.Weekday()] += score
+			counts[date.Weekday()]++
+		}
+	}
+	best, worst := math.Inf(-1), math.Inf(1)
+	if !data.Metrics[metric].isChoice() {
+		for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+			if counts[weekday] == 0 {
+				continue
+			}
+			average := sums[weekday] / float64(counts[weekday])
+			if average > best {
+				best, stats.bestDay = average, weekday.String()
+			}
+			if average < worst {
+				worst, stats.worstDay = average, weekday.String()
+			}
+		}
+	}
+
+	// month by month, how often a habit got done or the average
+	byMonth := map[string][]string{}
+	months := []time.Time{}
+	for idx, date := range dates {
+		month := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.Local)
+		if _, ok := byMonth[dayKey(month)]; !ok {
+			months = append(months, month)
+		}
+		byMonth[dayKey(month)] = append(byMonth[dayKey(month)], values[idx])
+	}
+	for _, month := range months {
+		stat := monthStat{month: month, average: "-"}
+		monthValues := byMonth[dayKey(month)]
+		switch {
+		case data.Metrics[metric].isBool():
+			done := 0
+			for _, value := range monthValues {
+				if value == "1" {
+					done++
+				}
+			}
+			stat.average = fmt.Sprintf("%d%%", done*100/len(monthValues))
+		case numeric:
+			monthScale := statsRule(rule, monthValues)
+			sum, count := 0.0, 0
+			for _, value := range monthValues {
+				if number, err := monthScale.Parse(value); err == nil {
+					sum += number
+					count++
+				}
+			}
+			if count > 0 {
+				stat.average = monthScale.Format(sum / float64(count))
+			}
+		}
+		stats.months = append(stats.months, stat)
+	}
+	return stats, nil
+}
+
+// the p-th percentile of sorted values, in between two values if need be
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	low := int(math.Floor(rank))
+	high := int(math.Ceil(rank))
+	return sorted[low] + (sorted[high]-sorted[low])*(rank-float64(low))
+}
+
+func stddev(values []float64) float64 {
+	mean := 0.0
+	for _, value := range values {
+		mean += value
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, value := range values {
+		variance += (value - mean) * (value - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
+
+// a spread is a length, so times of day are shown as durations, and whole
+// numbers get a digit after the point
+func formatSpread(rule Rule, spread float64) string {
+	switch rule.(type) {
+	case timeRule:
+		return durationRule{}.Format(spread)
+	case intRule:
+		return floatRule{decimals: 1}.Format(spread)
+	}
+	return rule.Format(spread)
+}
@@ -0,0 +1,120 @@
+package src
+
+import (
+	"fmt"
+	"time"
+)
+
+// ########################
+// ### STORAGE BACKENDS ###
+// ########################
+
+// Store is where entries live between runs. Views over a stretch of time
+// ask for just those days and a submit only hands over the one day that
+// changed. Metric values are keyed by metric ID, days by their date.
+type Store interface {
+	// LoadMetrics returns the metric layout the data was stored with.
+	LoadMetrics() ([]Metric, error)
+	// Load returns everything that has been tracked.
+	Load() (EntryData, error)
+	// Save replaces everything stored with data.
+	Save(data EntryData) error
+	// PutMetrics replaces the stored metric layout, e.g. after the config
+	// changed, leaving the entries alone.
+	PutMetrics(metrics []Metric) error
+	// GetDay returns the values tracked on the given day.
+	GetDay(day time.Time) (map[string]string, error)
+	// PutEntry replaces the values and the note of the given day in one go,
+	// an empty value removes the metric's value and an empty note the note.
+	PutEntry(day time.Time, values map[string]string, note string) error
+	// Range returns the entries and notes between from and to, both inclusive.
+	Range(from time.Time, to time.Time) (EntryData, error)
+	Close() error
+}
+
+// OpenStore opens the backend selected by Storage in the [general] section.
+func OpenStore(cfg Config) (Store, error) {
+	switch cfg.General.Storage {
+	case "", "json":
+		return jsonStore{}, nil
+	case "sqlite":
+		return openSQLiteStore(sqlitePath())
+	default:
+		return nil, fmt.Errorf("unknown storage %q, use \"json\" or \"sqlite\"", cfg.General.Storage)
+	}
+}
+
+// ##################
+// ## JSON BACKEND ##
+// ##################
+
+// the good old data.json, rewritten as a whole on every change
+type jsonStore struct{}
+
+func (s jsonStore) LoadMetrics() ([]Metric, error) {
+	data, err := loadJSON()
+	return data.Metrics, err
+}
+
+func (s jsonStore) Load() (EntryData, error) {
+	return loadJSON()
+}
+
+func (s jsonStore) Save(data EntryData) error {
+	if storeJSON(data) != 0 {
+		return fmt.Errorf("couldn't save %v", dataPath)
+	}
+	return nil
+}
+
+func (s jsonStore) PutMetrics(metrics []Metric) error {
+	data, err := loadJSON()
+	if err != nil {
+		return err
+	}
+	data.Metrics = metrics
+	return s.Save(data)
+}
+
+func (s jsonStore) GetDay(day time.Time) (map[string]string, error) {
+	data, err := loadJSON()
+	if err != nil {
+		return nil, err
+	}
+	return data.day(day), nil
+}
+
+func (s jsonStore) PutEntry(day time.Time, values map[string]string, note string) error {
+	data, err := loadJSON()
+	if err != nil {
+		return err
+	}
+	data.setDay(day, values)
+	data.setNote(day, note)
+	return s.Save(data)
+}
+
+func (s jsonStore) Range(from time.Time, to time.Time) (EntryData, error) {
+	data, err := loadJSON()
+	if err != nil {
+		return data, err
+	}
+	return data.between(from, to), nil
+}
+
+func (s jsonStore) Close() error {
+	return nil
+}
+
+// the days from from to to as the store has them, with the metrics as
+// configured and the values of derived metrics worked out like on load
+func loadRange(store Store, metrics []Metric, from time.Time, to time.Time) (EntryData, error) {
+	// derived metrics can look a few days back or ahead
+	reach := exprReach(metrics)
+	data, err := store.Range(from.AddDate(0, 0, -reach), to.AddDate(0, 0, reach))
+	if err != nil {
+		return data, err
+	}
+	data.Metrics = metrics
+	return deriveMetrics(data).between(from, to), nil
+}
@@ -0,0 +1,90 @@
+package src
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// #############
+// ## STREAKS ##
+// #############
+
+// days in a row that counted, from start to end. Days it got over on grace
+// are in between but not part of its length.
+type streak struct {
+	length int
+	start  time.Time
+	end    time.Time
+}
+
+// makes sure grace can be spent
+func (metric Metric) checkGrace() error {
+	if metric.Grace < 0 {
+		return fmt.Errorf("metric %v: grace can't be negative", metric.Name)
+	}
+	return nil
+}
+
+// the streak still going on as of now and the longest one so far. A streak
+// is still going if it ended today or yesterday, or if all the days after it
+// were skipped, not due or covered by grace. Every streak can get over as
+// many missed days as the metric has grace.
+func getStreaks(data EntryData, metric int, now time.Time) (streak, streak) {
+	today := dayStart(now.In(time.Local))
+	dates := streakDays(streakDates(data, metric), today)
+	if len(dates) == 0 {
+		return streak{}, streak{}
+	}
+	gaps := newStreakGaps(data, metric, dates)
+	grace := data.Metrics[metric].Grace
+
+	run := streak{length: 1, start: dates[0], end: dates[0]}
+	graceLeft := grace
+	longest := run
+	for _, date := range dates[1:] {
+		if missed := gaps.missed(run.end, date); missed <= graceLeft {
+			run.length++
+			run.end = date
+			graceLeft -= missed
+		} else {
+			run = streak{length: 1, start: date, end: date}
+			graceLeft = grace
+		}
+		if run.length > longest.length {
+			longest = run
+		}
+	}
+	if gaps.missed(run.end, today) > graceLeft {
+		return streak{}, longest
+	}
+	return run, longest
+}
+
+// the days of dates in the local calendar, each once, in order and none
+// after today
+func streakDays(dates []time.Time, today time.Time) []time.Time {
+	seen := map[string]bool{}
+	days := []time.Time{}
+	for _, date := range dates {
+		day := dayStart(date.In(time.Local))
+		if seen[dayKey(day)] || day.After(today) {
+			continue
+		}
+		seen[dayKey(day)] = true
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// from when to when the streak went, e.g. "3 Jan - 12 Jan 2023"
+func (s streak) span() string {
+	if s.length == 0 {
+		return ""
+	}
+	if s.start.Year() == s.end.Year() {
+		return s.start.Format("2 Jan") + " - " + s.end.Format("2 Jan 2006")
+	}
+	return s.start.Format("2 Jan 2006") + " - " + s.end.Format("2 Jan 2006")
+}
@@ -0,0 +1,161 @@
+package src
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// the data.json at the root of the repo, migrated to the current version
+func loadSample(t *testing.T) EntryData {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join("..", "data.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// migrating backs up the old file next to dataPath
+	oldPath := dataPath
+	dataPath = filepath.Join(t.TempDir(), "data.json")
+	defer func() { dataPath = oldPath }()
+	raw, _, err = migrateData(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data EntryData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func day(t *testing.T, key string) time.Time {
+	t.Helper()
+	date, err := time.ParseInLocation(dayFormat, key, time.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return date
+}
+
+func TestGetStreaks(t *testing.T) {
+	// "Got up" and "Mood" were tracked 2023-01-03 to 01-12, the others start
+	// on 01-17. All of them miss 01-22, 01-24, 01-29, 01-31 and 02-05 to 02-10.
+	tests := []struct {
+		name     string
+		metric   string
+		now      string
+		grace    int
+		schedule string
+		skip     []string
+		current  int
+		from, to string // current streak
+		longest  int
+		lFrom    string // longest streak
+		lTo      string
+	}{
+		{name: "ending today", metric: "Got up", now: "2023-02-04",
+			current: 4, from: "2023-02-01", to: "2023-02-04", longest: 10, lFrom: "2023-01-03", lTo: "2023-01-12"},
+		{name: "ending yesterday", metric: "Got up", now: "2023-02-05",
+			current: 4, from: "2023-02-01", to: "2023-02-04", longest: 10, lFrom: "2023-01-03", lTo: "2023-01-12"},
+		{name: "ended two days ago", metric: "Got up", now: "2023-02-06",
+			current: 0, longest: 10, lFrom: "2023-01-03", lTo: "2023-01-12"},
+		{name: "new streak after a break", metric: "Mood", now: "2023-02-11",
+			current: 1, from: "2023-02-11", to: "2023-02-11", longest: 10, lFrom: "2023-01-03", lTo: "2023-01-12"},
+		{name: "longest is the first of equals", metric: "Pomos", now: "2023-02-05",
+			current: 4, from: "2023-02-01", to: "2023-02-04", longest: 5, lFrom: "2023-01-17", lTo: "2023-01-21"},
+		{name: "before anything was tracked", metric: "Pomos", now: "2023-01-10",
+			current: 0, longest: 0},
+		{name: "later days are left out", metric: "Got up", now: "2023-01-07",
+			current: 5, from: "2023-01-03", to: "2023-01-07", longest: 5, lFrom: "2023-01-03", lTo: "2023-01-07"},
+		{name: "one grace day per streak", metric: "Got up", now: "2023-02-05", grace: 1,
+			current: 4, from: "2023-02-01", to: "2023-02-04", longest: 10, lFrom: "2023-01-03", lTo: "2023-01-12"},
+		{name: "grace covers the days since", metric: "Got up", now: "2023-02-06", grace: 2,
+			current: 5, from: "2023-01-30", to: "2023-02-04", longest: 10, lFrom: "2023-01-03", lTo: "2023-01-12"},
+		{name: "grace used up", metric: "Got up", now: "2023-02-07", grace: 2,
+			current: 0, longest: 10, lFrom: "2023-01-03", lTo: "2023-01-12"},
+		{name: "grace makes a longer streak", metric: "Drank water", now: "2023-02-05", grace: 2,
+			current: 5, from: "2023-01-30", to: "2023-02-04", longest: 10, lFrom: "2023-01-17", lTo: "2023-01-28"},
+		{name: "skipped days bridge", metric: "Drank water", now: "2023-02-05",
+			skip:    []string{"2023-01-22", "2023-01-24", "2023-01-29", "2023-01-31"},
+			current: 15, from: "2023-01-17", to: "2023-02-04", longest: 15, lFrom: "2023-01-17", lTo: "2023-02-04"},
+		{name: "skipped days since", metric: "Drank water", now: "2023-02-07",
+			skip:    []string{"2023-02-05", "2023-02-06"},
+			current: 4, from: "2023-02-01", to: "2023-02-04", longest: 5, lFrom: "2023-01-17", lTo: "2023-01-21"},
+		{name: "days not due bridge", metric: "Drank water", now: "2023-02-06", schedule: "mon wed thu fri sat",
+			current: 14, from: "2023-01-18", to: "2023-02-04", longest: 14, lFrom: "2023-01-18", lTo: "2023-02-04"},
+		{name: "missed a day that was due", metric: "Drank water", now: "2023-02-07", schedule: "mon wed thu fri sat",
+			current: 0, longest: 14, lFrom: "2023-01-18", lTo: "2023-02-04"},
+	}
+	sample := loadSample(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := EntryData{Metrics: append([]Metric{}, sample.Metrics...), Days: map[string]map[string]string{}}
+			for key, values := range sample.Days {
+				data.Days[key] = values
+			}
+			metric := -1
+			for idx := range data.Metrics {
+				if data.Metrics[idx].Name == tt.metric {
+					metric = idx
+				}
+			}
+			if metric < 0 {
+				t.Fatalf("no metric %v in the sample", tt.metric)
+			}
+			data.Metrics[metric].Grace = tt.grace
+			data.Metrics[metric].Schedule = tt.schedule
+			for _, key := range tt.skip {
+				data.Days[key] = map[string]string{data.Metrics[metric].key(): skipped}
+			}
+
+			// late in the evening here is already tomorrow further east
+			now := day(t, tt.now).Add(23 * time.Hour).In(time.FixedZone("", 3*60*60))
+			current, longest := getStreaks(data, metric, now)
+			if current.length != tt.current {
+				t.Errorf("current streak is %d, want %d", current.length, tt.current)
+			} else if tt.current > 0 && (!sameDay(current.start, day(t, tt.from)) || !sameDay(current.end, day(t, tt.to))) {
+				t.Errorf("current streak goes %v, want %v to %v", current.span(), tt.from, tt.to)
+			}
+			if longest.length != tt.longest {
+				t.Errorf("longest streak is %d, want %d", longest.length, tt.longest)
+			} else if tt.longest > 0 && (!sameDay(longest.start, day(t, tt.lFrom)) || !sameDay(longest.end, day(t, tt.lTo))) {
+				t.Errorf("longest streak goes %v, want %v to %v", longest.span(), tt.lFrom, tt.lTo)
+			}
+		})
+	}
+}
+
+func TestStreakDays(t *testing.T) {
+	today := day(t, "2023-01-10")
+	tests := []struct {
+		name  string
+		dates []time.Time
+		want  []string
+	}{
+		{name: "empty", dates: nil, want: []string{}},
+		{name: "unsorted", dates: []time.Time{day(t, "2023-01-05"), day(t, "2023-01-03"), day(t, "2023-01-04")},
+			want: []string{"2023-01-03", "2023-01-04", "2023-01-05"}},
+		{name: "same day twice", dates: []time.Time{day(t, "2023-01-03"), day(t, "2023-01-03").Add(20 * time.Hour)},
+			want: []string{"2023-01-03"}},
+		{name: "after today", dates: []time.Time{day(t, "2023-01-10"), day(t, "2023-01-11")},
+			want: []string{"2023-01-10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, date := range streakDays(tt.dates, today) {
+				got = append(got, dayKey(date))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for idx := range got {
+				if got[idx] != tt.want[idx] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
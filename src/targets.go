@@ -0,0 +1,135 @@
+package src
+
+import (
+	"fmt"
+	"github.com/lucasb-eyer/go-colorful"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// #######################
+// ## FREQUENCY TARGETS ##
+// #######################
+
+// how often a habit should be done per week or month, e.g. "3 per week" or
+// "20 days a month". A day counts if it would count towards a streak.
+type target struct {
+	times  int
+	period string // "week" or "month"
+	spec   string
+}
+
+func parseTarget(spec string) (target, error) {
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) > 0 {
+		times, err := strconv.Atoi(fields[0])
+		fields = fields[1:]
+		if len(fields) > 0 && (fields[0] == "times" || fields[0] == "days") {
+			fields = fields[1:]
+		}
+		if err == nil && len(fields) == 2 && (fields[0] == "per" || fields[0] == "a") {
+			t := target{times: times, period: strings.TrimSuffix(fields[1], "s"), spec: spec}
+			switch {
+			case t.period != "week" && t.period != "month":
+				return target{}, fmt.Errorf("target %q: the period has to be week or month", spec)
+			case times < 1 || (t.period == "week" && times > 7) || times > 31:
+				return target{}, fmt.Errorf("target %q: %d times don't fit into a %v", spec, times, t.period)
+			}
+			return t, nil
+		}
+	}
+	return target{}, fmt.Errorf("target %q should read like \"3 per week\" or \"20 days a month\"", spec)
+}
+
+// the target of the metric, ok is false if it has none
+func (metric Metric) target() (t target, ok bool, err error) {
+	if metric.Target == "" {
+		return target{}, false, nil
+	}
+	t, err = parseTarget(metric.Target)
+	if err != nil {
+		return target{}, false, fmt.Errorf("metric %v: %v", metric.Name, err)
+	}
+	return t, true, nil
+}
+
+// the first day of the week or month day is in
+func (t target) periodStart(day time.Time) time.Time {
+	if t.period == "month" {
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	return weekStart(day)
+}
+
+func (t target) nextPeriod(start time.Time) time.Time {
+	if t.period == "month" {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 7)
+}
+
+// how many days counted in each period, keyed by its first day
+func periodCounts(data EntryData, metric int, t target) map[string]int {
+	counts := map[string]int{}
+	for _, date := range streakDates(data, metric) {
+		counts[dayKey(t.periodStart(date))]++
+	}
+	return counts
+}
+
+// how far the period day is in got, e.g. 2 of 3 this week
+func getPeriodProgress(data EntryData, metric int, t target, day time.Time) (int, string) {
+	start := t.periodStart(day)
+	label := "this " + t.period
+	if !sameDay(start, t.periodStart(time.Now())) {
+		label = "week of " + start.Format("Jan 2")
+		if t.period == "month" {
+			label = start.Format("January 2006")
+		}
+	}
+	return periodCounts(data, metric, t)[dayKey(start)], label
+}
+
+// how many periods in a row met the target. The running period only adds to
+// the current streak once it is met, it doesn't break it before it is over.
+func periodStreaks(data EntryData, metric int, t target) (int, int) {
+	dates := streakDates(data, metric)
+	if len(dates) == 0 {
+		return 0, 0
+	}
+	counts := periodCounts(data, metric, t)
+	current := t.periodStart(time.Now())
+	streak, longest := 0, 0
+	for start := t.periodStart(dates[0]); !start.After(current); start = t.nextPeriod(start) {
+		if counts[dayKey(start)] >= t.times {
+			streak++
+		} else if !sameDay(start, current) {
+			streak = 0
+		}
+		longest = max(longest, streak)
+	}
+	return streak, longest
+}
+
+// the mondays of the weeks that met a weekly target
+func metWeeks(data EntryData, metric int) map[string]bool {
+	met := map[string]bool{}
+	t, ok, _ := data.Metrics[metric].target()
+	if !ok || t.period != "week" {
+		return met
+	}
+	for week, count := range periodCounts(data, metric, t) {
+		if count >= t.times {
+			met[week] = true
+		}
+	}
+	return met
+}
+
+// the color of the days in a week that met its target but weren't counted
+func metWeekColor(metric Metric) string {
+	done, _ := colorful.Hex(metric.Color2)
+	empty, _ := colorful.Hex("#D9DCCF")
+	return done.BlendLuv(empty, 0.5).Hex()
+}
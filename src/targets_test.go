@@ -0,0 +1,60 @@
+package src
+
+import "testing"
+
+func TestChoiceTargets(t *testing.T) {
+	// three weeks of workouts, starting on mondays
+	workouts := map[string]string{
+		"2023-01-02": "run", "2023-01-03": "gym", "2023-01-04": "none", "2023-01-05": "none", "2023-01-06": "run",
+		"2023-01-09": "none", "2023-01-10": "none", "2023-01-11": "none", "2023-01-12": "none", "2023-01-13": "none",
+		"2023-01-16": "run", "2023-01-17": "none", "2023-01-18": "gym",
+	}
+	weeks := []string{"2023-01-02", "2023-01-09", "2023-01-16"}
+	tests := []struct {
+		name   string
+		counts []string
+		done   []int // per week
+		met    []string
+	}{
+		{name: "all but the first choice", done: []int{3, 0, 2}, met: []string{"2023-01-02"}},
+		{name: "only the listed ones", counts: []string{"gym"}, done: []int{1, 0, 1}, met: []string{}},
+		{name: "none can be listed too", counts: []string{"none", "run", "gym"}, done: []int{5, 5, 3},
+			met: []string{"2023-01-02", "2023-01-09", "2023-01-16"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := Metric{ID: 1, Name: "Workout", Rule: "choice", Color1: "#83a598", Color2: "#abb31b",
+				Choices: []string{"none", "run", "gym"}, Counts: tt.counts, Target: "3 per week"}
+			data := EntryData{Metrics: []Metric{metric}, Days: map[string]map[string]string{}}
+			for key, value := range workouts {
+				data.Days[key] = map[string]string{metric.key(): value}
+			}
+			target, ok, err := metric.target()
+			if !ok || err != nil {
+				t.Fatalf("target didn't parse: %v", err)
+			}
+			counts := periodCounts(data, 0, target)
+			for idx, week := range weeks {
+				if counts[week] != tt.done[idx] {
+					t.Errorf("week of %v counted %d days, want %d", week, counts[week], tt.done[idx])
+				}
+			}
+			met := metWeeks(data, 0)
+			if len(met) != len(tt.met) {
+				t.Errorf("met the target in %v, want %v", met, tt.met)
+			}
+			for _, week := range tt.met {
+				if !met[week] {
+					t.Errorf("week of %v didn't meet the target", week)
+				}
+			}
+		})
+	}
+}
+
+func TestChoiceCountsMustBeChoices(t *testing.T) {
+	metric := Metric{Name: "Workout", Rule: "choice", Choices: []string{"none", "run"}, Counts: []string{"swim"}}
+	if _, err := metric.rule(); err == nil {
+		t.Error("counts with an unknown choice was taken")
+	}
+}
@@ -2,7 +2,7 @@ package src
 
 import (
 	"github.com/lucasb-eyer/go-colorful"
-	"strconv"
+	"math"
 	"time"
 )
 
@@ -22,21 +22,34 @@ func max(a, b int) int {
 	return b
 }
 
-func calcRangeMap(data EntryData) map[string][]float64 {
-	rangeMap := map[string][]float64{}
+func calcRangeMap(data EntryData) map[string]map[string]float64 {
+	rangeMap := map[string]map[string]float64{}
 	for index, _ := range data.Metrics {
 		// access data slice
-		rule := data.Metrics[index][1]
-		metric := data.Metrics[index][0]
-		currData := data.Data[index].Value
+		rule, err := data.Metrics[index].rule()
+		if err != nil {
+			// archived metrics may not have a rule anymore
+			continue
+		}
+		metric := data.Metrics[index].key()
+		dates, currData := data.series(metric)
 		// fmt.Printf("ZE CURR DATA: %v\n", currData)
 		// reformat data in there
-		formattedData := []int{}
-		for _, element := range currData {
-			formattedData = append(formattedData, reformatData(element, rule))
+		scale := statsRule(rule, currData)
+		formattedData := []float64{}
+		formattedDates := []time.Time{}
+		formattedValues := []string{}
+		for idx, element := range currData {
+			value, err := scale.Parse(element)
+			if err != nil {
+				continue
+			}
+			formattedData = append(formattedData, value)
+			formattedDates = append(formattedDates, dates[idx])
+			formattedValues = append(formattedValues, element)
 		}
 		// normalize to range {0,1}
-		var normalizedData []float64
+		normalizedData := map[string]float64{}
 		max := 1e-20
 		min := 1e20
 		for _, element := range formattedData {
@@ -47,12 +60,36 @@ func calcRangeMap(data EntryData) map[string][]float64 {
 				min = float64(element)
 			}
 		}
+		if ranged, ok := rule.(Ranged); ok {
+			if low, high, bounded := ranged.Range(); bounded {
+				min, max = low, high
+			}
+		}
+		// with a goal there are only met and missed days
+		g, hasGoal, _ := data.Metrics[index].goal()
+		if hasGoal {
+			for idx := range formattedData {
+				formattedData[idx] = 0
+				if data.Metrics[index].meetsGoal(g, formattedValues[idx]) {
+					formattedData[idx] = 1
+				}
+			}
+			min, max = 0, 1
+		}
 		max = max - min
+		if max <= 0 {
+			// all values are equal, they get the first color
+			max = 1
+		}
 		for idx, element := range formattedData {
-			formattedData[idx] = int(float64(element) - min)
+			formattedData[idx] = element - min
 		}
-		for _, element := range formattedData {
-			normalizedData = append(normalizedData, float64(element)/max)
+		for idx, element := range formattedData {
+			normalizedData[dayKey(formattedDates[idx])] = element / max
+			// the best days get color2 either way
+			if data.Metrics[index].lowerIsBetter() && !hasGoal {
+				normalizedData[dayKey(formattedDates[idx])] = 1 - element/max
+			}
 		}
 		rangeMap[metric] = normalizedData
 	}
@@ -60,117 +97,174 @@ func calcRangeMap(data EntryData) map[string][]float64 {
 }
 
 func getMinMaxAvg(data EntryData, metric int) (string, string, string) {
-	rule := data.Metrics[metric][1]
-	currMax := 0
-	currMin := 10000000000000
-	sumHours := 0
-	sumMins := 0
-	for _, element := range data.Data[metric].Value {
-		formattedElement := reformatData(element, rule)
-		if formattedElement > currMax {
-			currMax = formattedElement
-		}
-		if formattedElement < int(currMin) {
-			currMin = formattedElement
-		}
-		currHours := formattedElement / 100
-		sumHours += currHours
-		sumMins += formattedElement - (currHours * 100)
-	}
-	avgHours := sumHours / len(data.Data[metric].Value)
-	avgMins := sumMins / len(data.Data[metric].Value)
-	avg := avgHours*100 + avgMins
-	minString := formatData(currMin, rule)
-	maxString := formatData(currMax, rule)
-	avgString := formatData(avg, rule)
+	rule, err := data.Metrics[metric].rule()
+	if err != nil {
+		return "-", "-", "-"
+	}
+	_, values := data.series(data.Metrics[metric].key())
+	rule = statsRule(rule, values)
+	currMax := math.Inf(-1)
+	currMin := math.Inf(1)
+	sum := 0.0
+	count := 0
+	for _, element := range values {
+		formattedElement, err := rule.Parse(element)
+		if err != nil {
+			continue
+		}
+		currMax = math.Max(currMax, formattedElement)
+		currMin = math.Min(currMin, formattedElement)
+		sum += formattedElement
+		count++
+	}
+	if count == 0 {
+		return "-", "-", "-"
+	}
+	minString := rule.Format(currMin)
+	maxString := rule.Format(currMax)
+	avgString := rule.Format(sum / float64(count))
 
 	return minString, maxString, avgString
 }
 
-func streakChecker(data EntryData, metric int) (int, int) {
-	streak := 1
-	longestStreak := 0
-	// streakOK := true
-	dates := data.Data[metric].Date
-	for idx, element := range dates {
-		formDate := element.Format("02.01.2006")
-		year, _ := strconv.Atoi(formDate[len(formDate)-4 : len(formDate)])
-		prevMonth, _ := (strconv.Atoi(formDate[len(formDate)-7 : len(formDate)-5]))
-		month := time.Month(prevMonth)
-		day, _ := strconv.Atoi(formDate[len(formDate)-10 : len(formDate)-8])
-		// use the time.AddDate() method to check whether previous or following date exists in list
-		date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
-		nextDate := date.AddDate(0, 0, 1).Format("02.01.2006")
-		if len(dates) <= idx+1 {
-			// last element in the list
-
-		} else {
-			// next date must exist
-			nextDateInList := dates[idx+1].Format("02.01.2006")
-			if nextDateInList == nextDate {
-				// streak still ok
-				streak += 1
-			} else {
-				// streak broken
-				streak = 0
-			}
+// days that were skipped on purpose, somewhere between tracked and not
+const skippedColor = "#7C6F64"
+
+// days a scheduled habit wasn't due on, dimmed in the calendar
+const unscheduledColor = "#8A8C84"
+
+// the days that count towards a streak, habits only count when done,
+// avoided habits when clean, metrics with a goal when it was met and
+// choices when one of those in counts was picked
+func streakDates(data EntryData, metric int) []time.Time {
+	dates, values := data.series(data.Metrics[metric].key())
+	g, hasGoal, _ := data.Metrics[metric].goal()
+	avoided := data.Metrics[metric].avoided()
+	sched, hasSched, _ := data.Metrics[metric].schedule(data)
+	done := []time.Time{}
+	for idx, value := range values {
+		if hasSched && !sched.scheduled(dates[idx]) {
+			// done on a day off doesn't count
+			continue
 		}
-		if streak > longestStreak {
-			longestStreak = streak
+		if !data.Metrics[metric].isBool() && !hasGoal && !avoided {
+			if data.Metrics[metric].countsAsDone(value) {
+				done = append(done, dates[idx])
+			}
+		} else if avoided && data.Metrics[metric].clean(value) {
+			done = append(done, dates[idx])
+		} else if !avoided && hasGoal && data.Metrics[metric].meetsGoal(g, value) {
+			done = append(done, dates[idx])
+		} else if !avoided && !hasGoal && value == "1" {
+			done = append(done, dates[idx])
 		}
 	}
-	return streak, longestStreak
+	return done
 }
 
-func mapDataToGrid(data EntryData, grid [][]int, toShow time.Time, metric int, colorMap map[string][]string) [][]string {
-	// create map for date to number
-	dateMap := map[int]string{}
-	year := toShow.Format("02-01-2006")
-	year = year[len(year)-4 : len(year)]
-	year_as_int, err := strconv.Atoi(year)
+// on how many of the tracked days between from and to the goal was met,
+// as read from the store. Scheduled habits count against the times they
+// were due instead.
+func getGoalHits(store Store, data EntryData, metric int, g goal, from time.Time, to time.Time) (int, int, error) {
+	days, err := loadRange(store, data.Metrics, from, to)
 	if err != nil {
-		panic(err)
-	}
-	leapCheck := "29.02." + year
-	yearLength := 366
-	_, err2 := time.Parse("02.01.2006", leapCheck)
-	if err2 != nil {
-		yearLength = 365
-	}
-	start := time.Date(year_as_int, 1, 1, 0, 0, 0, 0, time.UTC)
-	for i := 1; i <= yearLength; i++ {
-		dateMap[i] = start.Format("02.01.2006")
-		start = start.AddDate(0, 0, 1)
-	}
-
-	// use date map to match
-	dateMask := map[int]string{}
-	for _, element := range data.Data[metric].Date {
-		element := element.Format("02.01.2006")
-		for index, element2 := range dateMap {
-			if element == element2 {
-				dateMask[index] = element
-			} else if dateMask[index] == "" {
-				dateMask[index] = "-"
+		return 0, 0, err
+	}
+	dates, values := days.series(data.Metrics[metric].key())
+	hit := []time.Time{}
+	for idx, value := range values {
+		if data.Metrics[metric].meetsGoal(g, value) {
+			hit = append(hit, dates[idx])
+		}
+	}
+	if due, ok := dueBetween(data, metric, from, to); ok {
+		return countDue(data, metric, hit), due, nil
+	}
+	return len(hit), len(values), nil
+}
+
+// how many times a scheduled habit was due between from and to, counting
+// from its first tracked day up to today. ok is false for daily metrics.
+func dueBetween(data EntryData, metric int, from time.Time, to time.Time) (int, bool) {
+	sched, ok, _ := data.Metrics[metric].schedule(data)
+	if !ok {
+		return 0, false
+	}
+	dates, _ := data.series(data.Metrics[metric].key())
+	if len(dates) == 0 {
+		return 0, true
+	}
+	if dates[0].After(from) {
+		from = dates[0]
+	}
+	if today := dayStart(time.Now()); today.Before(to) {
+		to = today
+	}
+	if to.Before(from) {
+		return 0, true
+	}
+	return sched.occurrences(from, to), true
+}
+
+// how many of the dates a scheduled habit was due on, a week only counts as
+// many times as it asks for
+func countDue(data EntryData, metric int, dates []time.Time) int {
+	sched, _, _ := data.Metrics[metric].schedule(data)
+	count := 0
+	perWeek := map[string]int{}
+	for _, date := range dates {
+		if !sched.scheduled(date) {
+			continue
+		}
+		if sched.perWeek > 0 {
+			week := dayKey(weekStart(date))
+			if perWeek[week] >= sched.perWeek {
+				continue
 			}
+			perWeek[week]++
+		}
+		count++
+	}
+	return count
+}
+
+func mapDataToGrid(data EntryData, grid [][]int, toShow time.Time, metric int, colorMap map[string]map[string]string) [][]string {
+	// walk the days of the year alongside the grid
+	day := time.Date(toShow.Year(), 1, 1, 0, 0, 0, 0, time.Local)
+	colors := colorMap[data.Metrics[metric].key()]
+	skippedDays := data.skippedDays(data.Metrics[metric].key())
+	sched, hasSched, _ := data.Metrics[metric].schedule(data)
+	// weeks that met a weekly target are filled in as a whole
+	weeksMet := metWeeks(data, metric)
+	counted := map[string]bool{}
+	if len(weeksMet) > 0 {
+		for _, date := range streakDates(data, metric) {
+			counted[dayKey(date)] = true
 		}
 	}
 	// loop over grid in overcomplicated way to make sure you understand
 	// how that shit works :)
 	coloredGrid := make([][]string, 7)
-	counter := 1
-	counter2 := 0
 	for i := 0; i < len(grid[0]); i++ {
 		for j := 0; j < 7; j++ {
 			if grid[j][i] != 0 {
-				if dateMask[counter] != "-" {
+				if weeksMet[dayKey(weekStart(day))] {
+					if counted[dayKey(day)] {
+						coloredGrid[j] = append(coloredGrid[j], data.Metrics[metric].Color2)
+					} else {
+						coloredGrid[j] = append(coloredGrid[j], metWeekColor(data.Metrics[metric]))
+					}
+				} else if color, ok := colors[dayKey(day)]; ok {
 					grid[j][i] = 2
-					coloredGrid[j] = append(coloredGrid[j], colorMap[data.Metrics[metric][0]][counter2])
-					counter2 += 1
+					coloredGrid[j] = append(coloredGrid[j], color)
+				} else if skippedDays[dayKey(day)] {
+					coloredGrid[j] = append(coloredGrid[j], skippedColor)
+				} else if hasSched && !sched.scheduled(day) {
+					coloredGrid[j] = append(coloredGrid[j], unscheduledColor)
 				} else {
 					coloredGrid[j] = append(coloredGrid[j], "#D9DCCF")
 				}
-				counter += 1
+				day = day.AddDate(0, 0, 1)
 			} else {
 				coloredGrid[j] = append(coloredGrid[j], "#383838")
 			}
@@ -181,30 +275,87 @@ func mapDataToGrid(data EntryData, grid [][]int, toShow time.Time, metric int, c
 
 }
 
-func getColorMap(rangeMap map[string][]float64, data EntryData, metric int) map[string][]string {
-	colorGrd := map[string][]string{}
+func getColorMap(rangeMap map[string]map[string]float64, data EntryData, metric int) map[string]map[string]string {
+	colorGrd := map[string]map[string]string{}
 	for index, _ := range rangeMap {
-		x0y0, _ := colorful.Hex(data.Metrics[metric][2])
-		x1y0, _ := colorful.Hex(data.Metrics[metric][3])
-		x0 := make([]string, len(rangeMap[index]))
-		for i := range x0 {
-			x0[i] = x0y0.BlendLuv(x1y0, rangeMap[index][i]).Hex()
-		}
-		// if all values are equal x0 will be #000000
-		if equalValues(x0) {
-			for i := 0; i < len(x0); i++ {
-				x0[i] = x0y0.Hex()
-			}
+		x0y0, _ := colorful.Hex(data.Metrics[metric].Color1)
+		x1y0, _ := colorful.Hex(data.Metrics[metric].Color2)
+		x0 := map[string]string{}
+		for day, value := range rangeMap[index] {
+			x0[day] = x0y0.BlendLuv(x1y0, value).Hex()
 		}
 		colorGrd[index] = x0
 	}
+	// categories bring their own colors
+	if rule, err := data.Metrics[metric].rule(); err == nil {
+		if colorer, ok := rule.(Colorer); ok {
+			x0 := map[string]string{}
+			dates, values := data.series(data.Metrics[metric].key())
+			for idx, value := range values {
+				if color, ok := colorer.Color(value); ok {
+					x0[dayKey(dates[idx])] = color
+				}
+			}
+			colorGrd[data.Metrics[metric].key()] = x0
+		}
+	}
 	return colorGrd
 }
-func equalValues(a []string) bool {
-	for i := 1; i < len(a); i++ {
-		if a[i] != a[0] {
-			return false
+
+// everything tracked for the metric added up, e.g. hours spent
+func getTotal(data EntryData, metric int) string {
+	rule, err := data.Metrics[metric].rule()
+	if err != nil {
+		return "-"
+	}
+	_, values := data.series(data.Metrics[metric].key())
+	sum := 0.0
+	for _, value := range values {
+		if parsed, err := rule.Parse(value); err == nil {
+			sum += parsed
+		}
+	}
+	return rule.Format(sum)
+}
+
+// the total of the week starting on the given monday, as read from the store
+func getWeekTotal(store Store, data EntryData, metric int, week time.Time) (string, error) {
+	days, err := loadRange(store, data.Metrics, week, week.AddDate(0, 0, 6))
+	if err != nil {
+		return "-", err
+	}
+	return getTotal(days, metric), nil
+}
+
+// how often each choice was picked, in the order of the config
+func getFrequencies(data EntryData, metric int) ([]string, []int) {
+	_, values := data.series(data.Metrics[metric].key())
+	counts := map[string]int{}
+	for _, value := range values {
+		counts[value]++
+	}
+	choices := data.Metrics[metric].Choices
+	frequencies := []int{}
+	for _, choice := range choices {
+		frequencies = append(frequencies, counts[choice])
+	}
+	return choices, frequencies
+}
+
+// how many of the tracked days a yes/no habit was done on, or for a
+// scheduled habit how many of the times it was due
+func getCompletion(data EntryData, metric int) (int, int) {
+	dates, values := data.series(data.Metrics[metric].key())
+	done := []time.Time{}
+	for idx, value := range values {
+		if value == "1" {
+			done = append(done, dates[idx])
+		}
+	}
+	if len(dates) > 0 {
+		if due, ok := dueBetween(data, metric, dates[0], dayStart(time.Now())); ok {
+			return countDue(data, metric, done), due
 		}
 	}
-	return true
+	return len(done), len(values)
 }